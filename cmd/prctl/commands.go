@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"pr-reviewer-service/pkg/client"
+	"sort"
+	"strings"
+	"time"
+)
+
+// parseFlags parses the flags of a command that takes exactly one
+// argument and returns that argument
+func parseFlags(fs *flag.FlagSet, args []string) (string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return "", fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%w: %s takes one argument", errUsage, fs.Name())
+	}
+	return fs.Arg(0), nil
+}
+
+func teamImport(ctx context.Context, c *client.Client, out *output, args []string) error {
+	path, err := parseFlags(flag.NewFlagSet("team import", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	var team client.Team
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&team); err != nil {
+		return fmt.Errorf("read team from %s: %w", path, err)
+	}
+
+	created, err := c.AddTeam(ctx, team)
+	if err != nil {
+		return err
+	}
+	return printTeam(out, created)
+}
+
+func teamGet(ctx context.Context, c *client.Client, out *output, args []string) error {
+	teamName, err := parseFlags(flag.NewFlagSet("team get", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	team, err := c.GetTeam(ctx, teamName)
+	if err != nil {
+		return err
+	}
+	return printTeam(out, team)
+}
+
+func printTeam(out *output, team *client.Team) error {
+	rows := make([][]string, len(team.Members))
+	for i, member := range team.Members {
+		member.TeamName = team.TeamName
+		rows[i] = userRow(&member)
+	}
+	return out.print(team, userHeader, rows)
+}
+
+func memberAdd(ctx context.Context, c *client.Client, out *output, args []string) error {
+	fs := flag.NewFlagSet("member add", flag.ContinueOnError)
+	teamName := fs.String("team", "", "team to add the user to")
+	username := fs.String("name", "", "username; defaults to the user ID")
+	inactive := fs.Bool("inactive", false, "add the user as inactive")
+	weight := fs.Float64("weight", 0, "relative share of reviews; 0 means the default")
+	maxOpen := fs.Int("max-open", 0, "open reviews at which the user gets no new ones")
+	userID, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *teamName == "" {
+		return fmt.Errorf("%w: -team is required", errUsage)
+	}
+	if *username == "" {
+		*username = userID
+	}
+
+	// Only flags given on the command line change an existing user
+	member := client.MemberChange{UserID: userID, Username: *username, TeamName: *teamName}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "inactive":
+			active := !*inactive
+			member.IsActive = &active
+		case "weight":
+			member.ReviewWeight = weight
+		case "max-open":
+			member.MaxOpenReviews = maxOpen
+		}
+	})
+	user, err := c.AddTeamMember(ctx, member)
+	if err != nil {
+		return err
+	}
+	return printUser(out, user)
+}
+
+func memberRemove(ctx context.Context, c *client.Client, out *output, args []string) error {
+	userID, err := parseFlags(flag.NewFlagSet("member remove", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.RemoveTeamMember(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return printUser(out, user)
+}
+
+func userActivate(ctx context.Context, c *client.Client, out *output, args []string) error {
+	return setActive(ctx, c, out, "user activate", args, true)
+}
+
+func userDeactivate(ctx context.Context, c *client.Client, out *output, args []string) error {
+	return setActive(ctx, c, out, "user deactivate", args, false)
+}
+
+func setActive(ctx context.Context, c *client.Client, out *output, name string, args []string, active bool) error {
+	userID, err := parseFlags(flag.NewFlagSet(name, flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.SetUserActive(ctx, userID, active)
+	if err != nil {
+		return err
+	}
+	return printUser(out, user)
+}
+
+var userHeader = []string{"USER_ID", "USERNAME", "TEAM", "ACTIVE", "WEIGHT", "MAX_OPEN", "OUT_OF_OFFICE_UNTIL"}
+
+func userRow(user *client.User) []string {
+	return []string{
+		user.UserID,
+		user.Username,
+		user.TeamName,
+		fmt.Sprint(user.IsActive),
+		fmt.Sprint(user.ReviewWeight),
+		formatInt(user.MaxOpenReviews),
+		formatTime(user.OutOfOfficeUntil),
+	}
+}
+
+func printUser(out *output, user *client.User) error {
+	return out.print(user, userHeader, [][]string{userRow(user)})
+}
+
+func prCreate(ctx context.Context, c *client.Client, out *output, args []string) error {
+	fs := flag.NewFlagSet("pr create", flag.ContinueOnError)
+	author := fs.String("author", "", "author's user ID")
+	name := fs.String("name", "", "PR title; defaults to the ID")
+	prID, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *author == "" {
+		return fmt.Errorf("%w: -author is required", errUsage)
+	}
+	if *name == "" {
+		*name = prID
+	}
+
+	pr, err := c.CreatePullRequest(ctx, client.CreatePullRequestRequest{
+		PullRequestID:   prID,
+		PullRequestName: *name,
+		AuthorID:        *author,
+	})
+	if err != nil {
+		return err
+	}
+	return out.print(pr, prHeader, [][]string{prRow(pr)})
+}
+
+func prMerge(ctx context.Context, c *client.Client, out *output, args []string) error {
+	fs := flag.NewFlagSet("pr merge", flag.ContinueOnError)
+	ifMatch := fs.Int64("if-match", 0, "only merge if the PR is still at this version")
+	prID, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	pr, err := c.MergePullRequest(ctx, prID, client.IfMatch(*ifMatch))
+	if err != nil {
+		return err
+	}
+	return out.print(pr, prHeader, [][]string{prRow(pr)})
+}
+
+func prReassign(ctx context.Context, c *client.Client, out *output, args []string) error {
+	fs := flag.NewFlagSet("pr reassign", flag.ContinueOnError)
+	userID := fs.String("user", "", "reviewer to replace")
+	reason := fs.String("reason", "", "why the reviewer is replaced")
+	ifMatch := fs.Int64("if-match", 0, "only reassign if the PR is still at this version")
+	prID, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *userID == "" {
+		return fmt.Errorf("%w: -user is required", errUsage)
+	}
+
+	pr, replacedBy, err := c.ReassignReviewer(ctx, client.ReassignRequest{
+		PullRequestID: prID,
+		OldUserID:     *userID,
+		Reason:        *reason,
+	}, client.IfMatch(*ifMatch))
+	if err != nil {
+		return err
+	}
+	result := map[string]interface{}{"pr": pr, "replaced_by": replacedBy}
+	header := append(append([]string{}, prHeader...), "REPLACED_BY")
+	return out.print(result, header, [][]string{append(prRow(pr), replacedBy)})
+}
+
+var prHeader = []string{"PR_ID", "NAME", "AUTHOR", "STATUS", "REVIEWERS", "VERSION"}
+
+func prRow(pr *client.PullRequest) []string {
+	reviewers := strings.Join(pr.AssignedReviewers, ",")
+	if len(pr.AssignedReviewers) < pr.RequestedReviewers {
+		reviewers += fmt.Sprintf(" (%d of %d)", len(pr.AssignedReviewers), pr.RequestedReviewers)
+	}
+	return []string{pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, reviewers, fmt.Sprint(pr.Version)}
+}
+
+func reviews(ctx context.Context, c *client.Client, out *output, args []string) error {
+	userID, err := parseFlags(flag.NewFlagSet("reviews", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	prs, err := c.GetUserReviews(ctx, userID)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(prs))
+	for i, pr := range prs {
+		rows[i] = []string{pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status}
+	}
+	return out.print(prs, []string{"PR_ID", "NAME", "AUTHOR", "STATUS"}, rows)
+}
+
+func stats(ctx context.Context, c *client.Client, out *output, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: stats needs reviewers, prs or cycle-time", errUsage)
+	}
+	kind := args[0]
+
+	fs := flag.NewFlagSet("stats "+kind, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var filter client.StatsFilter
+	fs.StringVar(&filter.TeamName, "team", "", "only count this team")
+	fs.Func("from", "only count PRs created at or after this time", timeFlag(&filter.From))
+	fs.Func("to", "only count PRs created before this time", timeFlag(&filter.To))
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: stats %s takes no arguments", errUsage, kind)
+	}
+
+	switch kind {
+	case "reviewers":
+		return reviewerStats(ctx, c, out, filter)
+	case "prs":
+		return pullRequestStats(ctx, c, out, filter)
+	case "cycle-time":
+		return cycleTimeStats(ctx, c, out, filter)
+	}
+	return fmt.Errorf("%w: unknown statistics %q", errUsage, kind)
+}
+
+func timeFlag(dest **time.Time) func(string) error {
+	return func(value string) error {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("must be an RFC 3339 timestamp")
+		}
+		*dest = &t
+		return nil
+	}
+}
+
+func reviewerStats(ctx context.Context, c *client.Client, out *output, filter client.StatsFilter) error {
+	reviewers, err := c.GetReviewerStats(ctx, filter)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(reviewers))
+	for i, r := range reviewers {
+		rows[i] = []string{r.UserID, r.Username, r.TeamName,
+			fmt.Sprint(r.Assignments), fmt.Sprint(r.OpenReviews), fmt.Sprint(r.MergedReviews), fmt.Sprint(r.ReassignedAway)}
+	}
+	return out.print(reviewers, []string{"USER_ID", "USERNAME", "TEAM", "ASSIGNMENTS", "OPEN", "MERGED", "REASSIGNED_AWAY"}, rows)
+}
+
+func pullRequestStats(ctx context.Context, c *client.Client, out *output, filter client.StatsFilter) error {
+	prs, err := c.GetPullRequestStats(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]string, 0, len(prs.ByStatus))
+	for status := range prs.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	rows := [][]string{{"total", fmt.Sprint(prs.Total)}}
+	for _, status := range statuses {
+		rows = append(rows, []string{strings.ToLower(status), fmt.Sprint(prs.ByStatus[status])})
+	}
+	rows = append(rows, []string{"median time to merge", formatSeconds(prs.MedianTimeToMergeSeconds)})
+	return out.print(prs, []string{"METRIC", "VALUE"}, rows)
+}
+
+func cycleTimeStats(ctx context.Context, c *client.Client, out *output, filter client.StatsFilter) error {
+	cycleTime, err := c.GetCycleTimeStats(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, group := range []struct {
+		kind    string
+		buckets []client.CycleTimeBucket
+	}{
+		{"team", cycleTime.Teams},
+		{"reviewer", cycleTime.Reviewers},
+	} {
+		for _, bucket := range group.buckets {
+			name := bucket.TeamName
+			if group.kind == "reviewer" {
+				name = bucket.ReviewerID
+			}
+			rows = append(rows, []string{
+				group.kind + " " + name,
+				bucket.Week.Format("2006-01-02"),
+				fmt.Sprint(bucket.PullRequests),
+				formatPercentiles(bucket.TimeToFirstReview),
+				formatPercentiles(bucket.TimeToApproval),
+				formatPercentiles(bucket.TimeToMerge),
+			})
+		}
+	}
+	header := []string{"GROUP", "WEEK", "PRS", "FIRST_REVIEW p50/p90/p99", "APPROVAL p50/p90/p99", "MERGE p50/p90/p99"}
+	return out.print(cycleTime, header, rows)
+}
+
+func formatPercentiles(p client.Percentiles) string {
+	return formatSeconds(p.P50) + "/" + formatSeconds(p.P90) + "/" + formatSeconds(p.P99)
+}
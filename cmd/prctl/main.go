@@ -0,0 +1,135 @@
+// Command prctl administers the PR reviewer assignment service from the
+// command line
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"pr-reviewer-service/pkg/client"
+	"time"
+)
+
+const usage = `Usage: prctl [flags] <command> [arguments]
+
+Commands:
+  team import FILE                      create a team from a JSON file ("-" reads stdin)
+  team get TEAM                         show a team and its members
+  member add -team TEAM [flags] USER    add a user to a team, moving them from their old one;
+                                        settings without a flag stay as they are
+  member remove USER                    remove a member from their team; PR history keeps them
+  user activate USER                    let a user be picked as a reviewer again
+  user deactivate USER                  stop picking a user as a reviewer
+  pr create -author USER [-name NAME] ID
+                                        create a PR and assign reviewers
+  pr merge [-if-match VERSION] ID       merge a PR
+  pr reassign -user USER [-reason TEXT] [-if-match VERSION] ID
+                                        replace a reviewer of a PR
+  reviews USER                          list the PRs a user reviews
+  stats reviewers|prs|cycle-time [-team TEAM] [-from TIME] [-to TIME]
+                                        show statistics; times are RFC 3339
+
+Flags:
+`
+
+// command runs a command with the arguments following its name
+type command func(ctx context.Context, c *client.Client, out *output, args []string) error
+
+var commands = map[string]map[string]command{
+	"team": {
+		"import": teamImport,
+		"get":    teamGet,
+	},
+	"member": {
+		"add":    memberAdd,
+		"remove": memberRemove,
+	},
+	"user": {
+		"activate":   userActivate,
+		"deactivate": userDeactivate,
+	},
+	"pr": {
+		"create":   prCreate,
+		"merge":    prMerge,
+		"reassign": prReassign,
+	},
+	"reviews": {"": reviews},
+	"stats":   {"": stats},
+}
+
+// errUsage makes main print the usage and exit with 2
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("prctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	server := fs.String("server", envOr("PRCTL_SERVER", "http://localhost:8080"), "service URL (PRCTL_SERVER)")
+	token := fs.String("token", os.Getenv("PRCTL_TOKEN"), "API token or JWT (PRCTL_TOKEN)")
+	format := fs.String("o", "table", "output format: table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the whole command, including retries")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(stderr, "prctl: unknown output format %q\n", *format)
+		return 2
+	}
+
+	cmd, cmdArgs := lookup(fs.Args())
+	if cmd == nil {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	c := client.New(*server, client.WithToken(*token), client.WithUserAgent("prctl"))
+	err := cmd(ctx, c, &output{w: stdout, json: *format == "json"}, cmdArgs)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "prctl: %v\n\n", err)
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "prctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// lookup finds the command named by the first one or two arguments
+func lookup(args []string) (command, []string) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	subcommands := commands[args[0]]
+	if cmd, ok := subcommands[""]; ok {
+		return cmd, args[1:]
+	}
+	if len(args) < 2 {
+		return nil, nil
+	}
+	if cmd, ok := subcommands[args[1]]; ok {
+		return cmd, args[2:]
+	}
+	return nil, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantArgs []string
+	}{
+		{"subcommand", []string{"team", "get", "backend"}, []string{"backend"}},
+		{"command without subcommands", []string{"reviews", "u1"}, []string{"u1"}},
+		{"command with positional argument", []string{"stats", "prs", "-team", "backend"}, []string{"prs", "-team", "backend"}},
+		{"unknown command", []string{"deploy", "now"}, nil},
+		{"unknown subcommand", []string{"team", "delete", "backend"}, nil},
+		{"missing subcommand", []string{"member"}, nil},
+		{"no arguments", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, args := lookup(tt.args)
+			if (cmd != nil) != (tt.wantArgs != nil) {
+				t.Fatalf("found command %v, want %v", cmd != nil, tt.wantArgs != nil)
+			}
+			if strings.Join(args, " ") != strings.Join(tt.wantArgs, " ") {
+				t.Errorf("got arguments %q, want %q", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// request is what the fake service saw of one call
+type request struct {
+	path string
+	body map[string]interface{}
+}
+
+func newServer(t *testing.T, status int, response string) (*httptest.Server, *[]request) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body := map[string]interface{}{}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Errorf("invalid request body %s: %v", data, err)
+			}
+		}
+		requests = append(requests, request{path: r.URL.Path, body: body})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+const userResponse = `{"user": {"user_id": "u1", "username": "u1", "team_name": "backend", "is_active": true, "review_weight": 2}}`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		status     int
+		response   string
+		wantCode   int
+		wantPath   string
+		wantBody   map[string]interface{}
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:     "member add sends only the flags given",
+			args:     []string{"member", "add", "-team", "backend", "-weight", "2", "u1"},
+			status:   http.StatusOK,
+			response: userResponse,
+			wantCode: 0,
+			wantPath: "/team/addMember",
+			wantBody: map[string]interface{}{
+				"user_id": "u1", "username": "u1", "team_name": "backend", "review_weight": 2.0,
+			},
+			wantStdout: "USER_ID",
+		},
+		{
+			name:     "member add with every setting",
+			args:     []string{"member", "add", "-team", "backend", "-inactive", "-max-open", "0", "u1"},
+			status:   http.StatusOK,
+			response: userResponse,
+			wantCode: 0,
+			wantPath: "/team/addMember",
+			wantBody: map[string]interface{}{
+				"user_id": "u1", "username": "u1", "team_name": "backend", "is_active": false, "max_open_reviews": 0.0,
+			},
+			wantStdout: "backend",
+		},
+		{
+			name:     "member remove",
+			args:     []string{"member", "remove", "u1"},
+			status:   http.StatusOK,
+			response: userResponse,
+			wantCode: 0,
+			wantPath: "/team/removeMember",
+			wantBody: map[string]interface{}{"user_id": "u1"},
+		},
+		{
+			name:       "missing team",
+			args:       []string{"member", "add", "u1"},
+			wantCode:   2,
+			wantStderr: "-team is required",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"member", "rename", "u1"},
+			wantCode:   2,
+			wantStderr: "Usage: prctl",
+		},
+		{
+			name:       "unknown output format",
+			args:       []string{"-o", "yaml", "reviews", "u1"},
+			wantCode:   2,
+			wantStderr: "unknown output format",
+		},
+		{
+			name:       "API error",
+			args:       []string{"member", "remove", "u9"},
+			status:     http.StatusNotFound,
+			response:   `{"error": {"code": "NOT_FOUND", "message": "user not found"}}`,
+			wantCode:   1,
+			wantPath:   "/team/removeMember",
+			wantBody:   map[string]interface{}{"user_id": "u9"},
+			wantStderr: "user not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newServer(t, tt.status, tt.response)
+			var stdout, stderr bytes.Buffer
+
+			code := run(append([]string{"-server", server.URL}, tt.args...), &stdout, &stderr)
+
+			if code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d; stderr: %s", code, tt.wantCode, stderr.String())
+			}
+			if tt.wantPath == "" {
+				if len(*requests) != 0 {
+					t.Errorf("sent %d requests, want none", len(*requests))
+				}
+			} else {
+				if len(*requests) != 1 {
+					t.Fatalf("sent %d requests, want 1", len(*requests))
+				}
+				got := (*requests)[0]
+				if got.path != tt.wantPath {
+					t.Errorf("requested %s, want %s", got.path, tt.wantPath)
+				}
+				gotBody, _ := json.Marshal(got.body)
+				wantBody, _ := json.Marshal(tt.wantBody)
+				if string(gotBody) != string(wantBody) {
+					t.Errorf("sent %s, want %s", gotBody, wantBody)
+				}
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("stdout %q does not contain %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr %q does not contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
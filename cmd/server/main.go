@@ -2,14 +2,26 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"pr-reviewer-service/internal/auth"
 	"pr-reviewer-service/internal/config"
 	"pr-reviewer-service/internal/handlers"
+	"pr-reviewer-service/internal/health"
+	"pr-reviewer-service/internal/httpx"
+	"pr-reviewer-service/internal/idempotency"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/metrics"
+	"pr-reviewer-service/internal/ratelimit"
 	"pr-reviewer-service/internal/repository"
 	"pr-reviewer-service/internal/service"
+	"pr-reviewer-service/internal/tracing"
+	"pr-reviewer-service/internal/worker"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -17,122 +29,266 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+const (
+	domainMetricsInterval   = 30 * time.Second
+	policyWatcherStaleAfter = 90 * time.Second
+
+	connectInitialBackoff = 500 * time.Millisecond
+	connectMaxBackoff     = 10 * time.Second
+
+	rateLimitCleanupInterval = 10 * time.Minute
+	rateLimitBucketIdle      = time.Hour
+
+	idempotencyCleanupInterval = 10 * time.Minute
+)
+
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	// Subscribe to SIGHUP before anything else: unhandled, it terminates the
+	// process, and config management may send one right after starting us.
+	// A hangup received during startup is handled once the server runs.
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
 
-	// Database connection with retry logic
-	var db *sqlx.DB
-	var err error
+	logger := logging.New(cfg.Log.Level)
+	slog.SetDefault(logger)
 
-	log.Println("Connecting to database...")
-	for i := 0; i < 10; i++ {
-		db, err = sqlx.Connect("pgx", cfg.DatabaseURL())
-		if err == nil {
-			break
-		}
-		log.Printf("Failed to connect to database (attempt %d/10): %v", i+1, err)
-		time.Sleep(2 * time.Second)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter)
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
 	}
+
+	// Database connection with retry logic
+	db, err := connect(cfg.DatabaseURL(), cfg.Pool.ConnectTimeout)
 	if err != nil {
-		log.Fatalf("Failed to connect to database after retries: %v", err)
+		return err
 	}
 	defer db.Close()
 
+	db.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+
 	// Test database connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
-		log.Fatalf("Database ping failed: %v", err)
+		return fmt.Errorf("database ping: %w", err)
 	}
-	log.Println("Successfully connected to database")
+	logger.Info("Successfully connected to database")
 
 	// Initialize database schema
-	if err := initDatabase(db); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	if err := repository.InitSchema(context.Background(), db); err != nil {
+		return fmt.Errorf("initialize database: %w", err)
 	}
-	log.Println("Database schema initialized")
+	logger.Info("Database schema initialized")
 
 	// Initialize dependencies
-	repo := repository.NewRepository(db)
-	svc := service.NewService(repo)
+	repo := repository.NewRepository(db, cfg.Pool.QueryTimeout)
+	svc := service.NewService(repo, rand.NewSource(time.Now().UnixNano()), cfg.Reviewers.Strategy)
+	if err := svc.SetDeclineQuota(declineQuota(cfg)); err != nil {
+		return err
+	}
+	if err := svc.ReloadPolicies(context.Background()); err != nil {
+		return fmt.Errorf("load team policies: %w", err)
+	}
 	handler := handlers.NewHandlers(svc)
 
+	// Background workers
+	metrics.RegisterDB(db)
+	workers := worker.NewGroup()
+	workers.Go("domain-metrics", 3*domainMetricsInterval, func(ctx context.Context) {
+		metrics.RefreshDomainGauges(ctx, repo, domainMetricsInterval)
+	})
+	workers.GoCritical("policy-watcher", policyWatcherStaleAfter, svc.WatchTeamPolicies)
+	checker := health.NewChecker(db, repo, workers)
+
 	// Setup routes
 	r := mux.NewRouter()
+	var limits *ratelimit.Middleware
+	if cfg.RateLimit.Enabled {
+		var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+		if cfg.RateLimit.Backend == "postgres" {
+			shared := ratelimit.NewPostgresLimiter(repo)
+			workers.Go("rate-limit-cleanup", 3*rateLimitCleanupInterval, func(ctx context.Context) {
+				shared.DeleteIdleBuckets(ctx, rateLimitBucketIdle, rateLimitCleanupInterval)
+			})
+			limiter = shared
+		}
+
+		routeLimits := make(map[string]ratelimit.Limit, len(cfg.RateLimit.Routes))
+		for route, limit := range cfg.RateLimit.Routes {
+			routeLimits[route] = ratelimit.Limit{Rate: limit.Rate, Burst: limit.Burst}
+		}
+		defaultLimit := ratelimit.Limit{Rate: cfg.RateLimit.Default.Rate, Burst: cfg.RateLimit.Default.Burst}
+		ipLimit := ratelimit.Limit{Rate: cfg.RateLimit.IP.Rate, Burst: cfg.RateLimit.IP.Burst}
+		limits = ratelimit.NewMiddleware(limiter, defaultLimit, routeLimits, ipLimit,
+			cfg.RateLimit.ClientIPHeader, cfg.RateLimit.TrustedProxies)
+	}
+
+	if cfg.Auth.Enabled {
+		// Requests are limited per IP before authentication, so failed
+		// logins are throttled too; once authenticated, per principal
+		if limits != nil {
+			r.Use(limits.IPHandler)
+		}
+		var verifier *auth.JWTVerifier
+		if cfg.Auth.JWT.JWKS != "" {
+			keys, err := auth.NewKeySet(context.Background(), cfg.Auth.JWT.JWKS)
+			if err != nil {
+				return err
+			}
+			verifier = auth.NewJWTVerifier(keys, cfg.Auth.JWT.Issuer, cfg.Auth.JWT.Audience, auth.ClaimMapping{
+				UserID: cfg.Auth.JWT.UserIDClaim,
+				Roles:  cfg.Auth.JWT.RolesClaim,
+				Team:   cfg.Auth.JWT.TeamClaim,
+			})
+			workers.Go("jwks-refresh", 3*cfg.Auth.JWT.RefreshInterval, func(ctx context.Context) {
+				verifier.RefreshKeys(ctx, cfg.Auth.JWT.RefreshInterval)
+			})
+		}
+		r.Use(auth.NewAuthenticator(repo, cfg.Auth.BootstrapToken, verifier).Middleware)
+	} else {
+		logger.Warn("Authentication is disabled; every endpoint is open")
+	}
+	if limits != nil {
+		r.Use(limits.Handler)
+	}
+	idempotent := idempotency.NewMiddleware(repo, cfg.Idempotency.TTL)
+	workers.Go("idempotency-cleanup", 3*idempotencyCleanupInterval, func(ctx context.Context) {
+		idempotent.DeleteExpired(ctx, idempotencyCleanupInterval)
+	})
+	r.Use(idempotent.Handler)
 
-	// Team endpoints
-	r.HandleFunc("/team/add", handler.AddTeam).Methods("POST")
-	r.HandleFunc("/team/get", handler.GetTeam).Methods("GET")
+	registerRoutes(r, handler, checker, db)
 
-	// User endpoints
-	r.HandleFunc("/users/setIsActive", handler.SetUserActive).Methods("POST")
-	r.HandleFunc("/users/getReview", handler.GetUserReviewPullRequests).Methods("GET")
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           httpx.WithRoutes(r, tracing.Middleware, logging.Middleware(logger), metrics.Middleware),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
 
-	// PR endpoints
-	r.HandleFunc("/pullRequest/create", handler.CreatePullRequest).Methods("POST")
-	r.HandleFunc("/pullRequest/merge", handler.MergePullRequest).Methods("POST")
-	r.HandleFunc("/pullRequest/reassign", handler.ReassignReviewer).Methods("POST")
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting", "port", cfg.Server.Port)
+		serverErr <- srv.ListenAndServe()
+	}()
 
-	// Health check
-	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	go reloadConfigOnSignal(signals, hangups, svc)
 
-		// Check database connection
-		if err := db.PingContext(r.Context()); err != nil {
-			http.Error(w, `{"status":"database error"}`, http.StatusServiceUnavailable)
-			return
-		}
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server stopped: %w", err)
+	case <-signals.Done():
+	}
+
+	// Fail readiness first and keep serving for DrainDelay, so load balancers
+	// stop routing here before the listener closes
+	logger.Info("Draining", "delay", cfg.Server.DrainDelay)
+	checker.SetDraining()
+	time.Sleep(cfg.Server.DrainDelay)
 
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	}).Methods("GET")
+	// Then stop taking requests and let in-flight ones finish, stop workers
+	// and flush traces; the deferred db.Close runs last
+	logger.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelShutdown()
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("HTTP server shutdown incomplete", "error", err)
+	}
+	if err := workers.Stop(shutdownCtx); err != nil {
+		logger.Error("Background workers did not stop in time", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("Tracing shutdown failed", "error", err)
 	}
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	logger.Info("Server stopped")
+	return nil
 }
 
-func initDatabase(db *sqlx.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS teams (
-            team_name VARCHAR(255) PRIMARY KEY,
-            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-        )`,
-
-		`CREATE TABLE IF NOT EXISTS users (
-            user_id VARCHAR(255) PRIMARY KEY,
-            username VARCHAR(255) NOT NULL,
-            team_name VARCHAR(255) NOT NULL REFERENCES teams(team_name) ON DELETE CASCADE,
-            is_active BOOLEAN DEFAULT true,
-            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-        )`,
-
-		`CREATE TABLE IF NOT EXISTS pull_requests (
-            pull_request_id VARCHAR(255) PRIMARY KEY,
-            pull_request_name VARCHAR(255) NOT NULL,
-            author_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
-            status VARCHAR(50) NOT NULL DEFAULT 'OPEN',
-            assigned_reviewers JSONB NOT NULL DEFAULT '[]',
-            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-            merged_at TIMESTAMP NULL,
-            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-        )`,
-
-		`CREATE INDEX IF NOT EXISTS idx_users_team_active ON users(team_name, is_active)`,
-		`CREATE INDEX IF NOT EXISTS idx_pr_author ON pull_requests(author_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_pr_status ON pull_requests(status)`,
-	}
-
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return err
+// connect opens the database, retrying with exponential backoff until
+// budget runs out
+func connect(databaseURL string, budget time.Duration) (*sqlx.DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	slog.Info("Connecting to database")
+	backoff := connectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := sqlx.ConnectContext(ctx, "pgx", databaseURL)
+		if err == nil {
+			return db, nil
+		}
+
+		deadline, _ := ctx.Deadline()
+		if time.Until(deadline) < backoff {
+			return nil, fmt.Errorf("connect to database after %d attempts: %w", attempt, err)
+		}
+		slog.Warn("Failed to connect to database", "attempt", attempt, "retry_in", backoff, "error", err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > connectMaxBackoff {
+			backoff = connectMaxBackoff
 		}
 	}
-	return nil
+}
+
+// reloadConfigOnSignal reloads the configuration on every SIGHUP received on
+// hangups until ctx is done. The log level, default reviewer strategy and
+// decline quota take effect immediately; other settings only apply after a
+// restart.
+func reloadConfigOnSignal(ctx context.Context, hangups <-chan os.Signal, svc *service.Service) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+		}
+
+		cfg, err := config.Load(os.Args[1:])
+		if err != nil {
+			slog.Error("Config reload failed, keeping current settings", "error", err)
+			continue
+		}
+		if err := svc.SetDefaultStrategy(cfg.Reviewers.Strategy); err != nil {
+			slog.Error("Config reload failed, keeping current settings", "error", err)
+			continue
+		}
+		if err := svc.SetDeclineQuota(declineQuota(cfg)); err != nil {
+			slog.Error("Config reload failed, keeping current settings", "error", err)
+			continue
+		}
+		logging.SetLevel(cfg.Log.Level)
+
+		slog.Info("Config reloaded; settings other than log level and reviewer settings apply after restart",
+			"log_level", cfg.Log.Level, "reviewer_strategy", cfg.Reviewers.Strategy,
+			"decline_quota", cfg.Reviewers.DeclineQuota, "decline_period", cfg.Reviewers.DeclinePeriod)
+	}
+}
+
+func declineQuota(cfg *config.Config) service.DeclineQuota {
+	return service.DeclineQuota{Limit: cfg.Reviewers.DeclineQuota, Period: cfg.Reviewers.DeclinePeriod}
 }
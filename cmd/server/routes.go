@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"pr-reviewer-service/internal/handlers"
+	"pr-reviewer-service/internal/health"
+	"pr-reviewer-service/internal/metrics"
+	"pr-reviewer-service/internal/openapi"
+
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+// docsPrefix is where Swagger UI is served
+const docsPrefix = "/docs/"
+
+// registerRoutes adds every endpoint of the service to r. Routes added here
+// must be described in internal/openapi/openapi.json.
+func registerRoutes(r *mux.Router, handler *handlers.Handlers, checker *health.Checker, db *sqlx.DB) {
+	// Team endpoints
+	r.HandleFunc("/team/add", handler.AddTeam).Methods("POST")
+	r.HandleFunc("/team/addMember", handler.AddTeamMember).Methods("POST")
+	r.HandleFunc("/team/removeMember", handler.RemoveTeamMember).Methods("POST")
+	r.HandleFunc("/team/get", handler.GetTeam).Methods("GET")
+	r.HandleFunc("/team/setPolicy", handler.SetTeamPolicy).Methods("POST")
+	r.HandleFunc("/team/getPolicy", handler.GetTeamPolicy).Methods("GET")
+
+	// User endpoints
+	r.HandleFunc("/users/setIsActive", handler.SetUserActive).Methods("POST")
+	r.HandleFunc("/users/setOutOfOffice", handler.SetUserOutOfOffice).Methods("POST")
+	r.HandleFunc("/users/getReview", handler.GetUserReviewPullRequests).Methods("GET")
+
+	// PR endpoints
+	r.HandleFunc("/pullRequest/create", handler.CreatePullRequest).Methods("POST")
+	r.HandleFunc("/pullRequest/preview", handler.PreviewPullRequest).Methods("POST")
+	r.HandleFunc("/pullRequest/merge", handler.MergePullRequest).Methods("POST")
+	r.HandleFunc("/pullRequest/reassign", handler.ReassignReviewer).Methods("POST")
+	r.HandleFunc("/pullRequest/review", handler.SubmitReview).Methods("POST")
+
+	// Stats endpoints
+	r.HandleFunc("/stats/reviewers", handler.GetReviewerStats).Methods("GET")
+	r.HandleFunc("/stats/pullRequests", handler.GetPullRequestStats).Methods("GET")
+	r.HandleFunc("/stats/cycleTime", handler.GetCycleTimeStats).Methods("GET")
+
+	// Token endpoints
+	r.HandleFunc("/tokens/issue", handler.IssueToken).Methods("POST")
+	r.HandleFunc("/tokens/list", handler.ListTokens).Methods("GET")
+	r.HandleFunc("/tokens/revoke", handler.RevokeToken).Methods("POST")
+
+	// API documentation
+	r.HandleFunc(openapi.SpecPath, openapi.SpecHandler).Methods("GET")
+	r.PathPrefix(docsPrefix).Handler(openapi.UIHandler(docsPrefix)).Methods("GET")
+
+	// Probes
+	r.HandleFunc("/livez", checker.Livez).Methods("GET")
+	r.HandleFunc("/readyz", checker.Readyz).Methods("GET")
+
+	// Health check
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Check database connection
+		if err := db.PingContext(r.Context()); err != nil {
+			http.Error(w, `{"status":"database error"}`, http.StatusServiceUnavailable)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}).Methods("GET")
+
+	// Prometheus metrics
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"pr-reviewer-service/internal/openapi"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec(), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	// Handlers aren't called, so the router can be built without dependencies
+	r := mux.NewRouter()
+	registerRoutes(r, nil, nil, nil)
+
+	registered := map[string]bool{}
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		// Swagger UI assets aren't part of the API
+		if path == docsPrefix {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("route %s doesn't restrict methods", path)
+			return nil
+		}
+		for _, method := range methods {
+			registered[method+" "+path] = true
+			if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s is registered but missing from openapi.json", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if !registered[strings.ToUpper(method)+" "+path] {
+				t.Errorf("%s %s is in openapi.json but not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
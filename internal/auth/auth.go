@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"pr-reviewer-service/internal/httpx"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/models"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// tokenPrefix marks API tokens so they are easy to spot in leaked text
+const tokenPrefix = "prs_"
+
+// Principal is the authenticated caller of a request. Callers with an API
+// token have a TokenID, and the UserID of the user the token was issued for
+// if any; callers with a JWT have the UserID of its subject.
+type Principal struct {
+	TokenID  string
+	UserID   string
+	Name     string
+	Role     string
+	TeamName string
+}
+
+// Actor identifies the principal in audit records: the user ID if known,
+// otherwise the API token
+func (p *Principal) Actor() string {
+	if p.UserID != "" {
+		return p.UserID
+	}
+	return "token:" + p.TokenID
+}
+
+// TokenStore looks up issued tokens
+type TokenStore interface {
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error)
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a context carrying principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal carried by ctx. There is none when
+// authentication is disabled.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(*Principal)
+	return principal, ok
+}
+
+// ActorFromContext returns the actor of the principal carried by ctx,
+// or an empty string if there is none
+func ActorFromContext(ctx context.Context) string {
+	if principal, ok := FromContext(ctx); ok {
+		return principal.Actor()
+	}
+	return ""
+}
+
+// GenerateToken returns a new random token and the hash to store for it
+func GenerateToken() (token string, tokenHash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of token. Tokens are random, so a plain
+// hash is enough to make a leaked table useless.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticator checks the bearer token of each request and whether its
+// role may call the matched route
+type Authenticator struct {
+	store          TokenStore
+	bootstrapToken string
+	jwt            *JWTVerifier
+}
+
+// NewAuthenticator returns an Authenticator looking API tokens up in store.
+// bootstrapToken, if not empty, is accepted as an admin token. JWTs are
+// accepted if verifier is not nil.
+func NewAuthenticator(store TokenStore, bootstrapToken string, verifier *JWTVerifier) *Authenticator {
+	return &Authenticator{store: store, bootstrapToken: bootstrapToken, jwt: verifier}
+}
+
+// Middleware rejects requests without a valid token with 401 and requests
+// whose role may not call the route with 403. Others continue with the
+// principal in their context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		if IsPublicRoute(route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.authenticate(r)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Token lookup failed", "error", err)
+			httpx.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+			return
+		}
+		if principal == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pr-reviewer-service"`)
+			httpx.WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "valid bearer token required")
+			return
+		}
+		if !Allowed(principal.Role, route) {
+			httpx.WriteError(w, http.StatusForbidden, "FORBIDDEN", "role "+principal.Role+" may not call "+route)
+			return
+		}
+
+		ctx := WithPrincipal(r.Context(), principal)
+		ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("actor", principal.Actor()))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate returns the principal of the request's bearer token,
+// or nil if it has no valid one
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, nil
+	}
+
+	if a.bootstrapToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.bootstrapToken)) == 1 {
+		return &Principal{TokenID: "bootstrap", Name: "bootstrap", Role: models.RoleAdmin}, nil
+	}
+
+	// API tokens never contain dots, JWTs always have two
+	if strings.Count(token, ".") == 2 {
+		if a.jwt == nil {
+			return nil, nil
+		}
+		principal, err := a.jwt.Verify(token)
+		if err != nil {
+			logging.FromContext(r.Context()).Debug("Rejected JWT", "error", err)
+			return nil, nil
+		}
+		return principal, nil
+	}
+
+	stored, err := a.store.GetAPITokenByHash(r.Context(), HashToken(token))
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return &Principal{
+		TokenID:  stored.TokenID,
+		UserID:   stored.UserID,
+		Name:     stored.Name,
+		Role:     stored.Role,
+		TeamName: stored.TeamName,
+	}, nil
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/worker"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtLeeway tolerates clock skew between us and the token issuer
+const jwtLeeway = 30 * time.Second
+
+// rolePrecedence orders roles from most to least privileged; a token
+// carrying several known roles gets the first of them
+var rolePrecedence = []string{models.RoleAdmin, models.RoleTeamLead, models.RoleBot, models.RoleReader}
+
+// ClaimMapping names the JWT claims the principal is read from. The roles
+// claim may be a string of space-separated roles or an array of strings.
+type ClaimMapping struct {
+	UserID string
+	Roles  string
+	Team   string
+}
+
+// JWTVerifier validates RS256 and ES256 tokens signed by a key in a KeySet
+// and turns their claims into a principal
+type JWTVerifier struct {
+	keys   *KeySet
+	parser *jwt.Parser
+	claims ClaimMapping
+}
+
+func NewJWTVerifier(keys *KeySet, issuer, audience string, claims ClaimMapping) *JWTVerifier {
+	return &JWTVerifier{
+		keys: keys,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+			jwt.WithIssuer(issuer),
+			jwt.WithAudience(audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(jwtLeeway),
+		),
+		claims: claims,
+	}
+}
+
+// Verify returns the principal of a valid token
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := v.keys.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := claims[v.claims.UserID].(string)
+	if userID == "" {
+		return nil, fmt.Errorf("claim %q is missing", v.claims.UserID)
+	}
+	role := highestRole(claimStrings(claims[v.claims.Roles]))
+	if role == "" {
+		return nil, fmt.Errorf("claim %q has no known role", v.claims.Roles)
+	}
+	teamName, _ := claims[v.claims.Team].(string)
+
+	return &Principal{
+		UserID:   userID,
+		Name:     userID,
+		Role:     role,
+		TeamName: teamName,
+	}, nil
+}
+
+// RefreshKeys reloads the key set every interval until ctx is done,
+// so rotated keys are picked up. It runs as a background worker.
+func (v *JWTVerifier) RefreshKeys(ctx context.Context, interval time.Duration) {
+	logger := logging.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := v.keys.Refresh(ctx); err != nil {
+			logger.Error("Failed to refresh JWKS", "error", err)
+			continue
+		}
+		worker.Heartbeat(ctx)
+	}
+}
+
+func claimStrings(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func highestRole(roles []string) string {
+	for _, role := range rolePrecedence {
+		for _, candidate := range roles {
+			if candidate == role {
+				return role
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"pr-reviewer-service/internal/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "pr-reviewer"
+)
+
+var testClaims = ClaimMapping{UserID: "sub", Roles: "roles", Team: "team"}
+
+// writeJWKS writes the public halves of keys, by kid, as a JWKS file
+func writeJWKS(t *testing.T, keys map[string]crypto.Signer) string {
+	t.Helper()
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch public := key.Public().(type) {
+		case *rsa.PublicKey:
+			doc.Keys = append(doc.Keys, jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig",
+				N: encode(public.N), E: encode(big.NewInt(int64(public.E)))})
+		case *ecdsa.PublicKey:
+			doc.Keys = append(doc.Keys, jsonWebKey{Kty: "EC", Kid: kid, Use: "sig", Crv: "P-256",
+				X: encode(public.X), Y: encode(public.Y)})
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newVerifier(t *testing.T, keys map[string]crypto.Signer) *JWTVerifier {
+	t.Helper()
+	set, err := NewKeySet(context.Background(), writeJWKS(t, keys))
+	if err != nil {
+		t.Fatalf("load JWKS: %v", err)
+	}
+	return NewJWTVerifier(set, testIssuer, testAudience, testClaims)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "u1",
+		"roles": []string{"reader", "team-lead"},
+		"team":  "backend",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func withClaim(name string, value interface{}) jwt.MapClaims {
+	claims := validClaims()
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	several := newVerifier(t, map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey})
+	single := newVerifier(t, map[string]crypto.Signer{"rsa": rsaKey})
+
+	tests := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		wantOK   bool
+	}{
+		{"RS256", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims()), true},
+		{"ES256", several, sign(t, jwt.SigningMethodES256, "ec", ecKey, validClaims()), true},
+		{"missing kid with a single key", single, sign(t, jwt.SigningMethodRS256, "", rsaKey, validClaims()), true},
+		{"expired within leeway", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey,
+			withClaim("exp", time.Now().Add(-jwtLeeway/2).Unix())), true},
+
+		{"alg none", several, sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, validClaims()), false},
+		{"HS256 signed with the public key", several, sign(t, jwt.SigningMethodHS256, "rsa", publicPEM, validClaims()), false},
+		{"RS512", several, sign(t, jwt.SigningMethodRS512, "rsa", rsaKey, validClaims()), false},
+		{"RS256 under the EC kid", several, sign(t, jwt.SigningMethodRS256, "ec", rsaKey, validClaims()), false},
+		{"signed by an unknown key", several, sign(t, jwt.SigningMethodRS256, "rsa", otherKey, validClaims()), false},
+		{"expired", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey,
+			withClaim("exp", time.Now().Add(-2*jwtLeeway).Unix())), false},
+		{"no expiry", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaim("exp", nil)), false},
+		{"wrong issuer", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaim("iss", "https://evil.example.com")), false},
+		{"wrong audience", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaim("aud", "other-service")), false},
+		{"unknown kid", several, sign(t, jwt.SigningMethodRS256, "rotated-away", rsaKey, validClaims()), false},
+		{"missing kid with several keys", several, sign(t, jwt.SigningMethodRS256, "", rsaKey, validClaims()), false},
+		{"no user", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaim("sub", nil)), false},
+		{"no known role", several, sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, withClaim("roles", "viewer")), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := tt.verifier.Verify(tt.token)
+			if !tt.wantOK {
+				if err == nil {
+					t.Fatalf("token accepted as %+v", principal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token rejected: %v", err)
+			}
+			want := Principal{UserID: "u1", Name: "u1", Role: models.RoleTeamLead, TeamName: "backend"}
+			if *principal != want {
+				t.Errorf("principal %+v, want %+v", *principal, want)
+			}
+		})
+	}
+}
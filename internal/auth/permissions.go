@@ -0,0 +1,77 @@
+package auth
+
+import "pr-reviewer-service/internal/models"
+
+// publicRoutes are served without a token: probes and metrics are scraped
+// by infrastructure that has none, and the API documentation is needed to
+// find out how to get one
+var publicRoutes = map[string]bool{
+	"/livez":   true,
+	"/readyz":  true,
+	"/health":  true,
+	"/metrics": true,
+
+	"/openapi.json": true,
+	"/docs/":        true,
+}
+
+// routeRoles lists the roles besides admin that may call each route. Admins
+// may call everything; routes missing here are admin-only. Team-leads are
+// further limited to their own team by the service.
+var routeRoles = map[string][]string{
+	"/team/add":          {models.RoleTeamLead},
+	"/team/addMember":    {models.RoleTeamLead},
+	"/team/removeMember": {models.RoleTeamLead},
+	"/team/get":          {models.RoleTeamLead, models.RoleReader},
+	"/team/setPolicy":    {models.RoleTeamLead},
+	"/team/getPolicy":    {models.RoleTeamLead, models.RoleReader},
+
+	"/users/setIsActive":    {models.RoleTeamLead},
+	"/users/setOutOfOffice": {models.RoleTeamLead},
+	"/users/getReview":      {models.RoleTeamLead, models.RoleReader},
+
+	"/pullRequest/create":  {models.RoleBot},
+	"/pullRequest/preview": {models.RoleTeamLead, models.RoleReader},
+	"/pullRequest/merge":   {models.RoleBot},
+
+	// Readers may only decline their own assignments, which needs a JWT or
+	// a token issued for their user; team-leads may reassign within their
+	// team. The service checks which applies.
+	"/pullRequest/reassign": {models.RoleTeamLead, models.RoleReader},
+
+	// Readers may only record their own reviews and team-leads those of
+	// their team; bots ingest reviews from the code host
+	"/pullRequest/review": {models.RoleTeamLead, models.RoleReader, models.RoleBot},
+
+	"/stats/reviewers":    {models.RoleTeamLead, models.RoleReader},
+	"/stats/pullRequests": {models.RoleTeamLead, models.RoleReader},
+	"/stats/cycleTime":    {models.RoleTeamLead, models.RoleReader},
+}
+
+// IsPublicRoute reports whether the route with the given path template is
+// served without a token
+func IsPublicRoute(route string) bool {
+	return publicRoutes[route]
+}
+
+// IsValidRole reports whether role is one tokens can be issued with
+func IsValidRole(role string) bool {
+	switch role {
+	case models.RoleAdmin, models.RoleTeamLead, models.RoleBot, models.RoleReader:
+		return true
+	}
+	return false
+}
+
+// Allowed reports whether role may call the route with the given path template
+func Allowed(role string, route string) bool {
+	if role == models.RoleAdmin {
+		return true
+	}
+	for _, allowed := range routeRoles[route] {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
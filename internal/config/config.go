@@ -1,37 +1,560 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
+	"pr-reviewer-service/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Config is assembled in increasing order of precedence from built-in
+// defaults, the YAML file given by -config or CONFIG_FILE, environment
+// variables and command-line flags. The settings table lists the env var
+// and flag for every field.
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
+	Database    DatabaseConfig    `yaml:"database"`
+	Pool        PoolConfig        `yaml:"pool"`
+	Server      ServerConfig      `yaml:"server"`
+	Auth        AuthConfig        `yaml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Reviewers   ReviewersConfig   `yaml:"reviewers"`
+	Log         LogConfig         `yaml:"log"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+}
+
+type DatabaseConfig struct {
+	// URL, when set, is used as is and the other fields are ignored
+	URL      string `yaml:"url"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+// PoolConfig holds the connection pool settings. QueryTimeout bounds every
+// repository statement; ConnectTimeout is the total time startup keeps
+// retrying the first connection.
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	QueryTimeout    time.Duration `yaml:"query_timeout"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+}
+
+// ServerConfig holds the HTTP server settings. On SIGINT/SIGTERM readiness
+// fails for DrainDelay before the listener closes; ShutdownTimeout then
+// bounds how long in-flight requests and background workers get to finish.
+type ServerConfig struct {
+	Port              string        `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	DrainDelay        time.Duration `yaml:"drain_delay"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+}
+
+type AuthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// BootstrapToken is accepted as an admin token so the first real
+	// tokens can be issued
+	BootstrapToken string    `yaml:"bootstrap_token"`
+	JWT            JWTConfig `yaml:"jwt"`
+}
+
+// JWTConfig enables JWT bearer tokens when JWKS, a file path or an
+// http(s) URL, is set. The claim settings name the claims the user ID,
+// roles and team (for team-leads) are read from.
+type JWTConfig struct {
+	JWKS            string        `yaml:"jwks"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	Issuer          string        `yaml:"issuer"`
+	Audience        string        `yaml:"audience"`
+	UserIDClaim     string        `yaml:"user_id_claim"`
+	RolesClaim      string        `yaml:"roles_claim"`
+	TeamClaim       string        `yaml:"team_claim"`
+}
+
+// RateLimitConfig holds the token bucket limits per client. Routes maps
+// path templates to their limit; other routes get Default. A zero rate
+// disables the limit. Backend "postgres" shares the buckets between replicas.
+// Clients without a token are told apart by IP, read from ClientIPHeader
+// behind proxies: the entry TrustedProxies from the right of the header.
+// With authentication enabled, IP also limits every request per address
+// before credentials are checked.
+type RateLimitConfig struct {
+	Enabled        bool                 `yaml:"enabled"`
+	Backend        string               `yaml:"backend"`
+	ClientIPHeader string               `yaml:"client_ip_header"`
+	TrustedProxies int                  `yaml:"trusted_proxies"`
+	Default        RateLimit            `yaml:"default"`
+	Routes         map[string]RateLimit `yaml:"routes"`
+	IP             RateLimit            `yaml:"ip"`
+}
+
+// RateLimit allows Rate requests per second on average and Burst at once
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// IdempotencyConfig sets how long responses to requests with an
+// Idempotency-Key are kept for replay
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// ReviewersConfig holds the reviewer selection settings. Reviewers may
+// decline at most DeclineQuota assignments per rolling DeclinePeriod;
+// zero disables the limit.
+type ReviewersConfig struct {
+	Strategy      string        `yaml:"strategy"`
+	DeclineQuota  int           `yaml:"decline_quota"`
+	DeclinePeriod time.Duration `yaml:"decline_period"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
 }
 
-func Load() *Config {
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"`
+}
+
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
 	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "password"),
-		DBName:     getEnv("DB_NAME", "pr_reviewer"),
+		Database: DatabaseConfig{
+			Host:    "localhost",
+			Port:    "5432",
+			User:    "postgres",
+			Name:    "pr_reviewer",
+			SSLMode: "disable",
+		},
+		Pool: PoolConfig{
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			QueryTimeout:    5 * time.Second,
+			ConnectTimeout:  30 * time.Second,
+		},
+		Server: ServerConfig{
+			Port:              "8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			DrainDelay:        5 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Auth: AuthConfig{
+			JWT: JWTConfig{
+				RefreshInterval: 10 * time.Minute,
+				UserIDClaim:     "sub",
+				RolesClaim:      "roles",
+				TeamClaim:       "team",
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        true,
+			Backend:        "memory",
+			TrustedProxies: 1,
+			Default:        RateLimit{Rate: 50, Burst: 100},
+			Routes: map[string]RateLimit{
+				"/pullRequest/create": {Rate: 5, Burst: 20},
+			},
+			IP: RateLimit{Rate: 100, Burst: 200},
+		},
+		Idempotency: IdempotencyConfig{TTL: 24 * time.Hour},
+		Reviewers: ReviewersConfig{
+			Strategy:      "random",
+			DeclineQuota:  3,
+			DeclinePeriod: 7 * 24 * time.Hour,
+		},
+		Log:     LogConfig{Level: "info"},
+		Tracing: TracingConfig{Exporter: "none"},
 	}
 }
 
+// setting binds one field to its environment variable and, optionally, a flag.
+// Secrets have no flag so they don't show up in the process list.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"DATABASE_URL", "database-url", "PostgreSQL connection URL, overrides the other database settings", setString(func(c *Config) *string { return &c.Database.URL })},
+	{"DB_HOST", "db-host", "database host", setString(func(c *Config) *string { return &c.Database.Host })},
+	{"DB_PORT", "db-port", "database port", setString(func(c *Config) *string { return &c.Database.Port })},
+	{"DB_USER", "db-user", "database user", setString(func(c *Config) *string { return &c.Database.User })},
+	{"DB_PASSWORD", "", "", setString(func(c *Config) *string { return &c.Database.Password })},
+	{"DB_NAME", "db-name", "database name", setString(func(c *Config) *string { return &c.Database.Name })},
+	{"DB_SSLMODE", "db-sslmode", "database sslmode", setString(func(c *Config) *string { return &c.Database.SSLMode })},
+
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections", setInt(func(c *Config) *int { return &c.Pool.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", setInt(func(c *Config) *int { return &c.Pool.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum database connection lifetime", setDuration(func(c *Config) *time.Duration { return &c.Pool.ConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum database connection idle time", setDuration(func(c *Config) *time.Duration { return &c.Pool.ConnMaxIdleTime })},
+	{"DB_QUERY_TIMEOUT", "db-query-timeout", "timeout for each database query, 0 to disable", setDuration(func(c *Config) *time.Duration { return &c.Pool.QueryTimeout })},
+	{"DB_CONNECT_TIMEOUT", "db-connect-timeout", "how long startup retries connecting to the database", setDuration(func(c *Config) *time.Duration { return &c.Pool.ConnectTimeout })},
+
+	{"PORT", "port", "HTTP listen port", setString(func(c *Config) *string { return &c.Server.Port })},
+	{"HTTP_READ_HEADER_TIMEOUT", "http-read-header-timeout", "HTTP read header timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout })},
+	{"HTTP_READ_TIMEOUT", "http-read-timeout", "HTTP read timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{"HTTP_WRITE_TIMEOUT", "http-write-timeout", "HTTP write timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{"HTTP_IDLE_TIMEOUT", "http-idle-timeout", "HTTP idle timeout", setDuration(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{"SHUTDOWN_DRAIN_DELAY", "shutdown-drain-delay", "how long readiness fails before the listener closes", setDuration(func(c *Config) *time.Duration { return &c.Server.DrainDelay })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long in-flight requests and workers get to finish", setDuration(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+
+	{"AUTH_ENABLED", "auth-enabled", "require API tokens", setBool(func(c *Config) *bool { return &c.Auth.Enabled })},
+	{"AUTH_BOOTSTRAP_TOKEN", "", "", setString(func(c *Config) *string { return &c.Auth.BootstrapToken })},
+	{"AUTH_JWKS", "auth-jwks", "JWKS file or URL; enables JWT bearer tokens", setString(func(c *Config) *string { return &c.Auth.JWT.JWKS })},
+	{"AUTH_JWKS_REFRESH_INTERVAL", "auth-jwks-refresh-interval", "how often the JWKS is reloaded", setDuration(func(c *Config) *time.Duration { return &c.Auth.JWT.RefreshInterval })},
+	{"AUTH_JWT_ISSUER", "auth-jwt-issuer", "required JWT issuer", setString(func(c *Config) *string { return &c.Auth.JWT.Issuer })},
+	{"AUTH_JWT_AUDIENCE", "auth-jwt-audience", "required JWT audience", setString(func(c *Config) *string { return &c.Auth.JWT.Audience })},
+	{"AUTH_JWT_USER_ID_CLAIM", "auth-jwt-user-id-claim", "JWT claim holding the user ID", setString(func(c *Config) *string { return &c.Auth.JWT.UserIDClaim })},
+	{"AUTH_JWT_ROLES_CLAIM", "auth-jwt-roles-claim", "JWT claim holding the roles", setString(func(c *Config) *string { return &c.Auth.JWT.RolesClaim })},
+	{"AUTH_JWT_TEAM_CLAIM", "auth-jwt-team-claim", "JWT claim holding a team-lead's team", setString(func(c *Config) *string { return &c.Auth.JWT.TeamClaim })},
+
+	{"RATE_LIMIT_ENABLED", "rate-limit-enabled", "limit request rates per client", setBool(func(c *Config) *bool { return &c.RateLimit.Enabled })},
+	{"RATE_LIMIT_BACKEND", "rate-limit-backend", "rate limiter backend: memory or postgres", setString(func(c *Config) *string { return &c.RateLimit.Backend })},
+	{"RATE_LIMIT_CLIENT_IP_HEADER", "rate-limit-client-ip-header", "header with the client IP, e.g. X-Forwarded-For", setString(func(c *Config) *string { return &c.RateLimit.ClientIPHeader })},
+	{"RATE_LIMIT_TRUSTED_PROXIES", "rate-limit-trusted-proxies", "proxies in front of the service that append to the client IP header", setInt(func(c *Config) *int { return &c.RateLimit.TrustedProxies })},
+	{"RATE_LIMIT_DEFAULT", "rate-limit-default", "default limit as rate:burst", setRateLimit(func(c *Config) *RateLimit { return &c.RateLimit.Default })},
+	{"RATE_LIMIT_ROUTES", "rate-limit-routes", "per-route limits as route=rate:burst,...", setRouteLimits(func(c *Config) *map[string]RateLimit { return &c.RateLimit.Routes })},
+
+	{"RATE_LIMIT_IP", "rate-limit-ip", "limit per IP before authentication as rate:burst", setRateLimit(func(c *Config) *RateLimit { return &c.RateLimit.IP })},
+
+	{"IDEMPOTENCY_TTL", "idempotency-ttl", "how long idempotent responses are kept for replay", setDuration(func(c *Config) *time.Duration { return &c.Idempotency.TTL })},
+
+	{"REVIEWER_STRATEGY", "strategy", "default reviewer selection strategy", setString(func(c *Config) *string { return &c.Reviewers.Strategy })},
+	{"REVIEWER_DECLINE_QUOTA", "decline-quota", "assignments a reviewer may decline per period, 0 for no limit", setInt(func(c *Config) *int { return &c.Reviewers.DeclineQuota })},
+	{"REVIEWER_DECLINE_PERIOD", "decline-period", "period the decline quota applies to", setDuration(func(c *Config) *time.Duration { return &c.Reviewers.DeclinePeriod })},
+	{"LOG_LEVEL", "log-level", "log level: debug, info, warn or error", setString(func(c *Config) *string { return &c.Log.Level })},
+	{"TRACING_EXPORTER", "tracing-exporter", "trace exporter: none, otlp or stdout", setString(func(c *Config) *string { return &c.Tracing.Exporter })},
+}
+
+// Load builds the configuration from args (without the program name),
+// the config file and the environment, and validates it
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		if s.flag != "" {
+			flagValues[s.flag] = fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := s.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Only flags given on the command line override, not their empty defaults
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(cfg, *flagValues[s.flag]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	defer f.Close()
+
+	// Unknown keys are rejected so typos don't silently fall back to defaults
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Database.URL != "" {
+		if u, err := url.Parse(c.Database.URL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			fail("database.url must be a postgres:// URL")
+		}
+	} else {
+		if c.Database.Host == "" {
+			fail("database.host is required")
+		}
+		if !validPort(c.Database.Port) {
+			fail("database.port must be a port number, got %q", c.Database.Port)
+		}
+		if c.Database.User == "" {
+			fail("database.user is required")
+		}
+		if c.Database.Password == "" {
+			fail("database.password is required (set DB_PASSWORD or DATABASE_URL)")
+		}
+		if c.Database.Name == "" {
+			fail("database.name is required")
+		}
+		switch c.Database.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			fail("database.sslmode %q is not a valid sslmode", c.Database.SSLMode)
+		}
+	}
+
+	if c.Pool.MaxOpenConns < 0 || c.Pool.MaxIdleConns < 0 {
+		fail("pool connection limits must not be negative")
+	}
+	if c.Pool.MaxOpenConns > 0 && c.Pool.MaxIdleConns > c.Pool.MaxOpenConns {
+		fail("pool.max_idle_conns (%d) must not exceed pool.max_open_conns (%d)", c.Pool.MaxIdleConns, c.Pool.MaxOpenConns)
+	}
+	if c.Pool.ConnMaxLifetime < 0 || c.Pool.ConnMaxIdleTime < 0 {
+		fail("pool connection lifetimes must not be negative")
+	}
+	if c.Pool.QueryTimeout < 0 {
+		fail("pool.query_timeout must not be negative")
+	}
+	if c.Pool.ConnectTimeout <= 0 {
+		fail("pool.connect_timeout must be positive")
+	}
+
+	if !validPort(c.Server.Port) {
+		fail("server.port must be a port number, got %q", c.Server.Port)
+	}
+	for name, d := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			fail("%s must be positive", name)
+		}
+	}
+	if c.Server.DrainDelay < 0 {
+		fail("server.drain_delay must not be negative")
+	}
+
+	if c.Auth.Enabled && c.Auth.BootstrapToken != "" && len(c.Auth.BootstrapToken) < 32 {
+		fail("auth.bootstrap_token must be at least 32 characters")
+	}
+	if c.Auth.JWT.JWKS != "" {
+		if c.Auth.JWT.Issuer == "" || c.Auth.JWT.Audience == "" {
+			fail("auth.jwt.issuer and auth.jwt.audience are required with auth.jwt.jwks")
+		}
+		if c.Auth.JWT.UserIDClaim == "" || c.Auth.JWT.RolesClaim == "" || c.Auth.JWT.TeamClaim == "" {
+			fail("auth.jwt claim names must not be empty")
+		}
+		if c.Auth.JWT.RefreshInterval <= 0 {
+			fail("auth.jwt.refresh_interval must be positive")
+		}
+	}
+
+	switch c.RateLimit.Backend {
+	case "memory", "postgres":
+	default:
+		fail("rate_limit.backend must be memory or postgres, got %q", c.RateLimit.Backend)
+	}
+	if c.RateLimit.ClientIPHeader != "" && c.RateLimit.TrustedProxies < 1 {
+		fail("rate_limit.trusted_proxies must be at least 1 with rate_limit.client_ip_header")
+	}
+	validateRateLimit := func(name string, limit RateLimit) {
+		if limit.Rate < 0 || (limit.Rate > 0 && limit.Burst < 1) {
+			fail("%s must have a non-negative rate and, when limited, a burst of at least 1", name)
+		}
+	}
+	validateRateLimit("rate_limit.default", c.RateLimit.Default)
+	validateRateLimit("rate_limit.ip", c.RateLimit.IP)
+	for route, limit := range c.RateLimit.Routes {
+		validateRateLimit("rate_limit.routes."+route, limit)
+	}
+
+	if c.Idempotency.TTL <= 0 {
+		fail("idempotency.ttl must be positive")
+	}
+
+	if !models.IsValidStrategy(c.Reviewers.Strategy) {
+		fail("reviewers.strategy must be %s, %s or %s, got %q",
+			models.StrategyRandom, models.StrategyPRSeeded, models.StrategyRoundRobin, c.Reviewers.Strategy)
+	}
+	if c.Reviewers.DeclineQuota < 0 {
+		fail("reviewers.decline_quota must not be negative")
+	}
+	if c.Reviewers.DeclineQuota > 0 && c.Reviewers.DeclinePeriod <= 0 {
+		fail("reviewers.decline_period must be positive")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("log.level must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		fail("tracing.exporter must be none, otlp or stdout, got %q", c.Tracing.Exporter)
+	}
+
+	return errors.Join(errs...)
+}
+
+// DatabaseURL returns Database.URL, or a URL assembled from the other
+// database settings with credentials escaped
 func (c *Config) DatabaseURL() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+	if c.Database.URL != "" {
+		return c.Database.URL
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.Database.User, c.Database.Password),
+		Host:     net.JoinHostPort(c.Database.Host, c.Database.Port),
+		Path:     "/" + c.Database.Name,
+		RawQuery: url.Values{"sslmode": {c.Database.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// setRateLimit parses "rate:burst"
+func setRateLimit(field func(*Config) *RateLimit) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		limit, err := parseRateLimit(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = limit
+		return nil
+	}
+}
+
+// setRouteLimits parses "route=rate:burst,..." and merges the routes into
+// the ones already configured
+func setRouteLimits(field func(*Config) *map[string]RateLimit) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		routes := field(c)
+		if *routes == nil {
+			*routes = map[string]RateLimit{}
+		}
+		for _, entry := range strings.Split(value, ",") {
+			route, limitValue, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || route == "" {
+				return fmt.Errorf("invalid route limit %q, want route=rate:burst", entry)
+			}
+			limit, err := parseRateLimit(limitValue)
+			if err != nil {
+				return err
+			}
+			(*routes)[route] = limit
+		}
+		return nil
+	}
+}
+
+func parseRateLimit(value string) (RateLimit, error) {
+	rateValue, burstValue, ok := strings.Cut(value, ":")
+	rate, rateErr := strconv.ParseFloat(rateValue, 64)
+	burst, burstErr := strconv.Atoi(burstValue)
+	if !ok || rateErr != nil || burstErr != nil {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, want rate:burst", value)
+	}
+	return RateLimit{Rate: rate, Burst: burst}, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+func setDuration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*field(c) = d
+		return nil
 	}
-	return value
 }
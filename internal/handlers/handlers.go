@@ -1,10 +1,14 @@
 package handlers
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"pr-reviewer-service/internal/httpx"
 	"pr-reviewer-service/internal/models"
 	"pr-reviewer-service/internal/service"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Handlers struct {
@@ -17,14 +21,32 @@ func NewHandlers(service *service.Service) *Handlers {
 
 func (h *Handlers) AddTeam(w http.ResponseWriter, r *http.Request) {
 	var team models.Team
-	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
-		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	if !decodeJSON(w, r, &team) {
+		return
+	}
+
+	var v validator
+	v.teamName("team_name", team.TeamName)
+	seen := make(map[string]bool, len(team.Members))
+	for i, member := range team.Members {
+		field := fmt.Sprintf("members[%d]", i)
+		v.id(field+".user_id", member.UserID)
+		v.check(!seen[member.UserID], field+".user_id", "is a duplicate")
+		seen[member.UserID] = true
+		v.name(field+".username", member.Username, maxNameLength)
+		v.check(member.TeamName == "" || member.TeamName == team.TeamName, field+".team_name", "must be empty or match team_name")
+		v.check(member.ReviewWeight >= 0, field+".review_weight", "must not be negative")
+		v.check(member.MaxOpenReviews == nil || *member.MaxOpenReviews >= 0, field+".max_open_reviews", "must not be negative")
+	}
+	if v.writeErrors(w) {
 		return
 	}
 
 	if err := h.service.CreateTeam(r.Context(), &team); err != nil {
 		if err.Error() == "team already exists" {
 			writeError(w, http.StatusBadRequest, "TEAM_EXISTS", "team_name already exists")
+		} else if err.Error() == "forbidden" {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "team-lead tokens may only change their own team")
 		} else {
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
 		}
@@ -34,6 +56,66 @@ func (h *Handlers) AddTeam(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, map[string]interface{}{"team": team})
 }
 
+func (h *Handlers) AddTeamMember(w http.ResponseWriter, r *http.Request) {
+	var member models.MemberChange
+	if !decodeJSON(w, r, &member) {
+		return
+	}
+
+	var v validator
+	v.id("user_id", member.UserID)
+	v.name("username", member.Username, maxNameLength)
+	v.lookup("team_name", member.TeamName)
+	v.check(member.ReviewWeight == nil || *member.ReviewWeight >= 0, "review_weight", "must not be negative")
+	v.check(member.MaxOpenReviews == nil || *member.MaxOpenReviews >= 0, "max_open_reviews", "must not be negative")
+	if v.writeErrors(w) {
+		return
+	}
+
+	user, err := h.service.AddTeamMember(r.Context(), &member)
+	if err != nil {
+		if err.Error() == "team not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		} else if err.Error() == "forbidden" {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "team-lead tokens may only change their own team")
+		} else {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user": user})
+}
+
+func (h *Handlers) RemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.lookup("user_id", req.UserID)
+	if v.writeErrors(w) {
+		return
+	}
+
+	user, err := h.service.RemoveTeamMember(r.Context(), req.UserID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		} else if err.Error() == "forbidden" {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "team-lead tokens may only change their own team")
+		} else {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user": user})
+}
+
 func (h *Handlers) GetTeam(w http.ResponseWriter, r *http.Request) {
 	teamName := r.URL.Query().Get("team_name")
 	if teamName == "" {
@@ -41,6 +123,12 @@ func (h *Handlers) GetTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var v validator
+	v.lookup("team_name", teamName)
+	if v.writeErrors(w) {
+		return
+	}
+
 	team, err := h.service.GetTeam(r.Context(), teamName)
 	if err != nil {
 		if err.Error() == "team not found" {
@@ -54,13 +142,78 @@ func (h *Handlers) GetTeam(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, team)
 }
 
+func (h *Handlers) SetTeamPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.TeamPolicy
+	if !decodeJSON(w, r, &policy) {
+		return
+	}
+
+	var v validator
+	v.lookup("team_name", policy.TeamName)
+	v.check(policy.Strategy != "", "strategy", "is required")
+	if v.writeErrors(w) {
+		return
+	}
+
+	if err := h.service.SetTeamPolicy(r.Context(), &policy); err != nil {
+		switch err.Error() {
+		case "team not found":
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		case "forbidden":
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "team-lead tokens may only change their own team")
+		case "unknown strategy":
+			writeError(w, http.StatusBadRequest, "UNKNOWN_STRATEGY", "unknown strategy")
+		case "invalid reviewer count":
+			writeError(w, http.StatusBadRequest, "INVALID_POLICY", "reviewer_count must be between 1 and 10")
+		case "invalid affinity settings":
+			writeError(w, http.StatusBadRequest, "INVALID_POLICY", "affinity_window must be >= 0 and affinity_penalty in (0, 1]")
+		default:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"policy": policy})
+}
+
+func (h *Handlers) GetTeamPolicy(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		writeError(w, http.StatusBadRequest, "MISSING_PARAMETER", "team_name parameter is required")
+		return
+	}
+
+	var v validator
+	v.lookup("team_name", teamName)
+	if v.writeErrors(w) {
+		return
+	}
+
+	policy, err := h.service.GetTeamPolicy(r.Context(), teamName)
+	if err != nil {
+		if err.Error() == "team not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"policy": policy})
+}
+
 func (h *Handlers) SetUserActive(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		UserID   string `json:"user_id"`
 		IsActive bool   `json:"is_active"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.lookup("user_id", req.UserID)
+	if v.writeErrors(w) {
 		return
 	}
 
@@ -68,6 +221,38 @@ func (h *Handlers) SetUserActive(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if err.Error() == "user not found" {
 			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		} else if err.Error() == "forbidden" {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "team-lead tokens may only change their own team")
+		} else {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user": user})
+}
+
+func (h *Handlers) SetUserOutOfOffice(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID string     `json:"user_id"`
+		Until  *time.Time `json:"until"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.lookup("user_id", req.UserID)
+	if v.writeErrors(w) {
+		return
+	}
+
+	user, err := h.service.SetUserOutOfOffice(r.Context(), req.UserID, req.Until)
+	if err != nil {
+		if err.Error() == "user not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		} else if err.Error() == "forbidden" {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "team-lead tokens may only change their own team")
 		} else {
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
 		}
@@ -83,8 +268,15 @@ func (h *Handlers) CreatePullRequest(w http.ResponseWriter, r *http.Request) {
 		PullRequestName string `json:"pull_request_name"`
 		AuthorID        string `json:"author_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.id("pull_request_id", req.PullRequestID)
+	v.name("pull_request_name", req.PullRequestName, maxNameLength)
+	v.lookup("author_id", req.AuthorID)
+	if v.writeErrors(w) {
 		return
 	}
 
@@ -107,28 +299,82 @@ func (h *Handlers) CreatePullRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, createdPR)
 	writeJSON(w, http.StatusCreated, map[string]interface{}{"pr": createdPR})
 }
 
+func (h *Handlers) PreviewPullRequest(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PullRequestID string `json:"pull_request_id"`
+		AuthorID      string `json:"author_id"`
+		Strategy      string `json:"strategy"`
+		Seed          *int64 `json:"seed"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	if req.PullRequestID != "" {
+		v.id("pull_request_id", req.PullRequestID)
+	}
+	v.lookup("author_id", req.AuthorID)
+	if v.writeErrors(w) {
+		return
+	}
+
+	preview, err := h.service.PreviewPullRequest(r.Context(), req.PullRequestID, req.AuthorID, req.Strategy, req.Seed)
+	if err != nil {
+		switch err.Error() {
+		case "author not found":
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		case "unknown strategy":
+			writeError(w, http.StatusBadRequest, "UNKNOWN_STRATEGY", "unknown strategy")
+		default:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"preview": preview})
+}
+
 func (h *Handlers) MergePullRequest(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		PullRequestID string `json:"pull_request_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.lookup("pull_request_id", req.PullRequestID)
+	if v.writeErrors(w) {
+		return
+	}
+
+	ifMatch, ok := parseIfMatch(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "If-Match must be an ETag returned for the PR")
 		return
 	}
 
-	pr, err := h.service.MergePullRequest(r.Context(), req.PullRequestID)
+	pr, err := h.service.MergePullRequest(r.Context(), req.PullRequestID, ifMatch)
 	if err != nil {
-		if err.Error() == "PR not found" {
+		switch err.Error() {
+		case "PR not found":
 			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
-		} else {
+		case "PR version mismatch":
+			writeError(w, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "PR was modified since the given ETag")
+		case "PR version conflict":
+			writeError(w, http.StatusConflict, "VERSION_CONFLICT", "PR is being modified concurrently, retry")
+		default:
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
 		}
 		return
 	}
 
+	setETag(w, pr)
 	writeJSON(w, http.StatusOK, map[string]interface{}{"pr": pr})
 }
 
@@ -136,13 +382,26 @@ func (h *Handlers) ReassignReviewer(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		PullRequestID string `json:"pull_request_id"`
 		OldUserID     string `json:"old_user_id"`
+		Reason        string `json:"reason"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.lookup("pull_request_id", req.PullRequestID)
+	v.lookup("old_user_id", req.OldUserID)
+	if v.writeErrors(w) {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+
+	ifMatch, ok := parseIfMatch(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "If-Match must be an ETag returned for the PR")
 		return
 	}
 
-	pr, newUserID, err := h.service.ReassignReviewer(r.Context(), req.PullRequestID, req.OldUserID)
+	pr, newUserID, err := h.service.ReassignReviewer(r.Context(), req.PullRequestID, req.OldUserID, req.Reason, ifMatch)
 	if err != nil {
 		switch err.Error() {
 		case "PR not found":
@@ -155,18 +414,69 @@ func (h *Handlers) ReassignReviewer(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
 		case "no active replacement candidate in team":
 			writeError(w, http.StatusConflict, "NO_CANDIDATE", "no active replacement candidate in team")
+		case "reason too long":
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "reason must be at most 500 bytes")
+		case "forbidden":
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "only the reviewer, their team-lead or an admin may reassign")
+		case "PR version mismatch":
+			writeError(w, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "PR was modified since the given ETag")
+		case "PR version conflict":
+			writeError(w, http.StatusConflict, "VERSION_CONFLICT", "PR is being modified concurrently, retry")
+		case "decline quota exceeded":
+			writeError(w, http.StatusTooManyRequests, "DECLINE_QUOTA_EXCEEDED", "decline quota exceeded, ask your team-lead to reassign")
 		default:
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
 		}
 		return
 	}
 
+	setETag(w, pr)
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"pr":          pr,
 		"replaced_by": newUserID,
 	})
 }
 
+func (h *Handlers) SubmitReview(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PullRequestID string `json:"pull_request_id"`
+		ReviewerID    string `json:"reviewer_id"`
+		State         string `json:"state"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.lookup("pull_request_id", req.PullRequestID)
+	v.lookup("reviewer_id", req.ReviewerID)
+	v.check(req.State != "", "state", "is required")
+	if v.writeErrors(w) {
+		return
+	}
+
+	review, err := h.service.SubmitReview(r.Context(), req.PullRequestID, req.ReviewerID, req.State)
+	if err != nil {
+		switch err.Error() {
+		case "invalid review state":
+			writeError(w, http.StatusBadRequest, "INVALID_STATE", "state must be COMMENTED, APPROVED or CHANGES_REQUESTED")
+		case "forbidden":
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "only the reviewer, their team-lead, a bot or an admin may record a review")
+		case "PR not found", "user not found":
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		case "cannot review merged PR":
+			writeError(w, http.StatusConflict, "PR_MERGED", "cannot review merged PR")
+		case "reviewer is not assigned to this PR":
+			writeError(w, http.StatusConflict, "NOT_ASSIGNED", "reviewer is not assigned to this PR")
+		default:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"review": review})
+}
+
 func (h *Handlers) GetUserReviewPullRequests(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
@@ -174,24 +484,82 @@ func (h *Handlers) GetUserReviewPullRequests(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	prs, err := h.service.GetUserReviewPullRequests(r.Context(), userID)
+	var v validator
+	v.lookup("user_id", userID)
+	if v.writeErrors(w) {
+		return
+	}
+
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	prs, next, err := h.service.GetUserReviewPullRequests(r.Context(), userID, page)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	writeJSON(w, http.StatusOK, withNextCursor(map[string]interface{}{
 		"user_id":       userID,
 		"pull_requests": prs,
-	})
+	}, next))
 }
 
-func writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+// parsePage reads the optional limit and cursor query parameters of a list
+// endpoint, writing a 400 response if they are malformed
+func parsePage(w http.ResponseWriter, r *http.Request) (models.PageRequest, bool) {
+	query := r.URL.Query()
+	page := models.PageRequest{Cursor: query.Get("cursor")}
+
+	var v validator
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		v.check(err == nil && n >= 1 && n <= maxPageSize, "limit", fmt.Sprintf("must be an integer from 1 to %d", maxPageSize))
+		page.Limit = n
+	}
+	if page.Cursor != "" {
+		v.lookup("cursor", page.Cursor)
+	}
+	return page, !v.writeErrors(w)
+}
+
+// withNextCursor adds next_cursor to a list response unless it is the last page
+func withNextCursor(response map[string]interface{}, next string) map[string]interface{} {
+	if next != "" {
+		response["next_cursor"] = next
+	}
+	return response
+}
+
+// setETag exposes the PR's version as a strong ETag
+func setETag(w http.ResponseWriter, pr *models.PullRequest) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(pr.Version, 10)+`"`)
+}
+
+// parseIfMatch returns the PR version named by the If-Match header, or
+// zero if there is none or it is "*". ok is false if the header doesn't
+// hold a single ETag set by setETag.
+func parseIfMatch(r *http.Request) (version int64, ok bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, true
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, false
 	}
+	version, err = strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	httpx.WriteJSON(w, status, data)
 }
 
 func writeError(w http.ResponseWriter, status int, code string, message ...string) {
@@ -199,11 +567,5 @@ func writeError(w http.ResponseWriter, status int, code string, message ...strin
 	if len(message) > 0 {
 		errorMsg = message[0]
 	}
-
-	writeJSON(w, status, map[string]interface{}{
-		"error": map[string]string{
-			"code":    code,
-			"message": errorMsg,
-		},
-	})
+	httpx.WriteError(w, status, code, errorMsg)
 }
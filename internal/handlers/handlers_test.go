@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/service"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		wantOK bool
+	}{
+		{"", 0, true},
+		{"*", 0, true},
+		{`"3"`, 3, true},
+		{` "3" `, 3, true},
+		{"3", 0, false},
+		{`W/"3"`, 0, false},
+		{`"0"`, 0, false},
+		{`"-1"`, 0, false},
+		{`"abc"`, 0, false},
+		{`"3", "4"`, 0, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/pullRequest/merge", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		got, ok := parseIfMatch(r)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("If-Match %q: got %d, %v, want %d, %v", tt.header, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// racingStore serves one PR whose every update loses a race with a
+// concurrent update
+type racingStore struct {
+	service.Store
+}
+
+func (racingStore) GetPullRequest(ctx context.Context, prID string) (*models.PullRequest, error) {
+	return &models.PullRequest{PullRequestID: prID, Status: "OPEN", Version: 1}, nil
+}
+
+func (racingStore) UpdatePullRequest(ctx context.Context, pr *models.PullRequest) error {
+	return errors.New("PR version conflict")
+}
+
+func TestMergeVersionConflictStatuses(t *testing.T) {
+	h := NewHandlers(service.NewService(racingStore{}, rand.NewSource(1), models.StrategyRandom))
+	tests := []struct {
+		name       string
+		ifMatch    string
+		wantStatus int
+		wantCode   string
+	}{
+		{"without If-Match", "", http.StatusConflict, "VERSION_CONFLICT"},
+		{"with If-Match", `"1"`, http.StatusPreconditionFailed, "PRECONDITION_FAILED"},
+		{"stale If-Match", `"7"`, http.StatusPreconditionFailed, "PRECONDITION_FAILED"},
+		{"malformed If-Match", "1", http.StatusBadRequest, "INVALID_REQUEST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/pullRequest/merge", strings.NewReader(`{"pull_request_id": "pr-1"}`))
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			h.MergePullRequest(w, r)
+
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), `"`+tt.wantCode+`"`) {
+				t.Errorf("got %d %s, want %d %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+// memberStore records the change AddTeamMember passes on
+type memberStore struct {
+	service.Store
+	change *models.MemberChange
+}
+
+func (s *memberStore) AddTeamMember(ctx context.Context, member *models.MemberChange) (*models.User, error) {
+	s.change = member
+	return &models.User{UserID: member.UserID, Username: member.Username, TeamName: member.TeamName}, nil
+}
+
+func TestAddTeamMemberPassesOnlySentSettings(t *testing.T) {
+	active, weight, defaultWeight, maxOpen := false, 2.5, 1.0, 0
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       *models.MemberChange
+	}{
+		{"no settings", `{"user_id": "u1", "username": "Alice", "team_name": "backend"}`, http.StatusOK,
+			&models.MemberChange{UserID: "u1", Username: "Alice", TeamName: "backend"}},
+		{"all settings", `{"user_id": "u1", "username": "Alice", "team_name": "backend",
+			"is_active": false, "review_weight": 2.5, "max_open_reviews": 0}`, http.StatusOK,
+			&models.MemberChange{UserID: "u1", Username: "Alice", TeamName: "backend",
+				IsActive: &active, ReviewWeight: &weight, MaxOpenReviews: &maxOpen}},
+		{"zero weight resets to the default", `{"user_id": "u1", "username": "Alice", "team_name": "backend",
+			"review_weight": 0}`, http.StatusOK,
+			&models.MemberChange{UserID: "u1", Username: "Alice", TeamName: "backend", ReviewWeight: &defaultWeight}},
+		{"negative cap", `{"user_id": "u1", "username": "Alice", "team_name": "backend",
+			"max_open_reviews": -1}`, http.StatusBadRequest, nil},
+		{"out of office", `{"user_id": "u1", "username": "Alice", "team_name": "backend",
+			"out_of_office_until": "2030-01-01T00:00:00Z"}`, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memberStore{}
+			h := NewHandlers(service.NewService(store, rand.NewSource(1), models.StrategyRandom))
+
+			w := httptest.NewRecorder()
+			h.AddTeamMember(w, httptest.NewRequest("POST", "/team/addMember", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d %s, want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+			if !reflect.DeepEqual(store.change, tt.want) {
+				t.Errorf("store got %s, want %s", describeChange(store.change), describeChange(tt.want))
+			}
+		})
+	}
+}
+
+func describeChange(change *models.MemberChange) string {
+	if change == nil {
+		return "nothing"
+	}
+	data, _ := json.Marshal(change)
+	return string(data)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"pr-reviewer-service/internal/models"
+	"time"
+)
+
+func (h *Handlers) GetReviewerStats(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseStatsFilter(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.GetReviewerStats(r.Context(), filter)
+	if err != nil {
+		writeStatsError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reviewers": stats})
+}
+
+func (h *Handlers) GetPullRequestStats(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseStatsFilter(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.GetPullRequestStats(r.Context(), filter)
+	if err != nil {
+		writeStatsError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pull_requests": stats})
+}
+
+// parseStatsFilter reads the optional team_name, from and to (RFC 3339)
+// query parameters, writing a 400 response if they are malformed
+func parseStatsFilter(w http.ResponseWriter, r *http.Request) (models.StatsFilter, bool) {
+	query := r.URL.Query()
+	filter := models.StatsFilter{TeamName: query.Get("team_name")}
+	if filter.TeamName != "" {
+		var v validator
+		v.lookup("team_name", filter.TeamName)
+		if v.writeErrors(w) {
+			return filter, false
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", param.name+" must be an RFC 3339 timestamp")
+			return filter, false
+		}
+		t = t.UTC()
+		*param.dest = &t
+	}
+
+	return filter, true
+}
+
+func writeStatsError(w http.ResponseWriter, err error) {
+	if err.Error() == "invalid time range" {
+		writeError(w, http.StatusBadRequest, "INVALID_PARAMETER", "from must be before to")
+	} else {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+	}
+}
+
+func (h *Handlers) GetCycleTimeStats(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseStatsFilter(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.GetCycleTimeStats(r.Context(), filter)
+	if err != nil {
+		writeStatsError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"cycle_time": stats})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func (h *Handlers) IssueToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     string `json:"name"`
+		Role     string `json:"role"`
+		TeamName string `json:"team_name"`
+		UserID   string `json:"user_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.name("name", req.Name, maxNameLength)
+	v.check(req.Role != "", "role", "is required")
+	if req.TeamName != "" {
+		v.lookup("team_name", req.TeamName)
+	}
+	if req.UserID != "" {
+		v.lookup("user_id", req.UserID)
+	}
+	if v.writeErrors(w) {
+		return
+	}
+
+	token, err := h.service.IssueToken(r.Context(), req.Name, req.Role, req.TeamName, req.UserID)
+	if err != nil {
+		switch err.Error() {
+		case "token name is required", "team_name is required for team-lead tokens only":
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		case "unknown role":
+			writeError(w, http.StatusBadRequest, "UNKNOWN_ROLE", "role must be admin, team-lead, bot or reader")
+		case "team not found", "user not found":
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"token": token})
+}
+
+func (h *Handlers) ListTokens(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, next, err := h.service.ListTokens(r.Context(), page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, withNextCursor(map[string]interface{}{"tokens": tokens}, next))
+}
+
+func (h *Handlers) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TokenID string `json:"token_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.lookup("token_id", req.TokenID)
+	if v.writeErrors(w) {
+		return
+	}
+
+	token, err := h.service.RevokeToken(r.Context(), req.TokenID)
+	if err != nil {
+		if err.Error() == "token not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"token": token})
+}
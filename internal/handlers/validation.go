@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"pr-reviewer-service/internal/httpx"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodySize caps request bodies; larger ones get 413
+const maxBodySize = 1 << 20
+
+// Length limits of validated fields
+const (
+	maxIDLength       = 64
+	maxTeamNameLength = 100
+	maxNameLength     = 255
+
+	// maxLookupLength matches the database columns, so any stored ID or
+	// team name can be looked up
+	maxLookupLength = 255
+)
+
+// maxPageSize caps the limit of list endpoints
+const maxPageSize = 1000
+
+var (
+	// IDs are used in URLs, logs and metric labels, so they are kept to a
+	// safe character set
+	idPattern       = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]*$`)
+	teamNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._-]*$`)
+)
+
+// fieldError describes why one field of a request is invalid
+type fieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// validator collects the problems of a request so they can all be
+// reported at once
+type validator struct {
+	errors []fieldError
+}
+
+func (v *validator) fail(field, reason string) {
+	v.errors = append(v.errors, fieldError{Field: field, Reason: reason})
+}
+
+// check records reason for field unless ok
+func (v *validator) check(ok bool, field, reason string) {
+	if !ok {
+		v.fail(field, reason)
+	}
+}
+
+// id validates a required ID
+func (v *validator) id(field, value string) {
+	switch {
+	case value == "":
+		v.fail(field, "is required")
+	case len(value) > maxIDLength:
+		v.fail(field, fmt.Sprintf("must be at most %d characters", maxIDLength))
+	case !idPattern.MatchString(value):
+		v.fail(field, "may only contain letters, digits, '.', '_', ':' and '-', starting with a letter or digit")
+	}
+}
+
+// teamName validates a required team name
+func (v *validator) teamName(field, value string) {
+	switch {
+	case value == "":
+		v.fail(field, "is required")
+	case len(value) > maxTeamNameLength:
+		v.fail(field, fmt.Sprintf("must be at most %d characters", maxTeamNameLength))
+	case !teamNamePattern.MatchString(value) || strings.HasSuffix(value, " "):
+		v.fail(field, "may only contain letters, digits, spaces, '.', '_' and '-', starting and ending with a letter or digit")
+	}
+}
+
+// lookup validates a required ID or team name that refers to an existing
+// entity. Those may predate the stricter patterns, so only the length is
+// checked.
+func (v *validator) lookup(field, value string) {
+	switch {
+	case value == "":
+		v.fail(field, "is required")
+	case len(value) > maxLookupLength:
+		v.fail(field, fmt.Sprintf("must be at most %d characters", maxLookupLength))
+	}
+}
+
+// name validates required free text up to max characters
+func (v *validator) name(field, value string, max int) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		v.fail(field, "is required")
+	case !utf8.ValidString(value):
+		v.fail(field, "must be valid UTF-8")
+	case utf8.RuneCountInString(value) > max:
+		v.fail(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// writeErrors reports the collected problems as VALIDATION_FAILED and
+// returns true, or returns false if there are none
+func (v *validator) writeErrors(w http.ResponseWriter) bool {
+	if len(v.errors) == 0 {
+		return false
+	}
+	writeValidationError(w, v.errors...)
+	return true
+}
+
+// decodeJSON decodes the request body into dst, rejecting unknown fields,
+// trailing data and bodies over maxBodySize. On failure it writes the error
+// response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("trailing data after JSON body")
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", fmt.Sprintf("request body must be at most %d bytes", maxBodySize))
+	case errors.As(err, &typeErr):
+		writeValidationError(w, fieldError{Field: fieldPath(typeErr.Field), Reason: "must be " + jsonTypeName(typeErr.Type.Kind().String())})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		writeValidationError(w, fieldError{Field: field, Reason: "is not a known field"})
+	default:
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	}
+	return false
+}
+
+func writeValidationError(w http.ResponseWriter, details ...fieldError) {
+	httpx.WriteErrorDetails(w, http.StatusBadRequest, "VALIDATION_FAILED", "request validation failed", details)
+}
+
+// fieldPath rewrites the decoder's dotted path ("members.0.user_id") in the
+// bracketed form the validator reports ("members[0].user_id").
+func fieldPath(path string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// jsonTypeName names the JSON type a Go kind is decoded from
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "string":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	case "slice", "array":
+		return "an array"
+	case "struct", "map", "ptr":
+		return "an object"
+	default:
+		return "a number"
+	}
+}
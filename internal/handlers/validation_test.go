@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/service"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errorBody is the error envelope as clients see it
+type errorBody struct {
+	Error struct {
+		Code    string       `json:"code"`
+		Message string       `json:"message"`
+		Details []fieldError `json:"details"`
+	} `json:"error"`
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	var body errorBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	return body
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type request struct {
+		Name    string `json:"name"`
+		Members []struct {
+			UserID   string `json:"user_id"`
+			IsActive bool   `json:"is_active"`
+		} `json:"members"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantOK     bool
+		wantStatus int
+		wantCode   string
+		wantDetail fieldError
+	}{
+		{name: "valid", body: `{"name": "a", "members": [{"user_id": "u1"}]}`, wantOK: true},
+		{name: "trailing whitespace", body: "{\"name\": \"a\"}\n", wantOK: true},
+		{name: "unknown field", body: `{"name": "a", "nmae": "b"}`,
+			wantStatus: http.StatusBadRequest, wantCode: "VALIDATION_FAILED",
+			wantDetail: fieldError{Field: "nmae", Reason: "is not a known field"}},
+		{name: "wrong type in array", body: `{"members": [{"user_id": "u1"}, {"is_active": "yes"}]}`,
+			wantStatus: http.StatusBadRequest, wantCode: "VALIDATION_FAILED",
+			wantDetail: fieldError{Field: "members[1].is_active", Reason: "must be a boolean"}},
+		{name: "trailing data", body: `{"name": "a"} {"name": "b"}`,
+			wantStatus: http.StatusBadRequest, wantCode: "INVALID_REQUEST"},
+		{name: "malformed", body: `{"name": `,
+			wantStatus: http.StatusBadRequest, wantCode: "INVALID_REQUEST"},
+		{name: "too large", body: `{"name": "` + strings.Repeat("a", maxBodySize) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: "BODY_TOO_LARGE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/team/add", strings.NewReader(tt.body))
+
+			var dst request
+			if ok := decodeJSON(w, r, &dst); ok != tt.wantOK {
+				t.Fatalf("decodeJSON returned %v, want %v", ok, tt.wantOK)
+			}
+			if tt.wantOK {
+				return
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := decodeError(t, w)
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("code %q, want %q", body.Error.Code, tt.wantCode)
+			}
+			if tt.wantDetail != (fieldError{}) && !reflect.DeepEqual(body.Error.Details, []fieldError{tt.wantDetail}) {
+				t.Errorf("details %+v, want %+v", body.Error.Details, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	tests := map[string]string{
+		"":                     "",
+		"name":                 "name",
+		"members.0.user_id":    "members[0].user_id",
+		"members.12.is_active": "members[12].is_active",
+		"matrix.1.2":           "matrix[1][2]",
+		"0":                    "0",
+	}
+	for path, want := range tests {
+		if got := fieldPath(path); got != want {
+			t.Errorf("fieldPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAddTeamRejectsDuplicateMembers(t *testing.T) {
+	// Validation fails before the service is called, so it needs no store
+	h := NewHandlers(service.NewService(nil, rand.NewSource(1), models.StrategyRandom))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/team/add", strings.NewReader(`{"team_name": "backend", "members": [
+		{"user_id": "u1", "username": "Alice", "is_active": true},
+		{"user_id": "u2", "username": "Bob", "is_active": true},
+		{"user_id": "u1", "username": "Alice again", "is_active": true}]}`))
+	h.AddTeam(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	body := decodeError(t, w)
+	want := []fieldError{{Field: "members[2].user_id", Reason: "is a duplicate"}}
+	if body.Error.Code != "VALIDATION_FAILED" || !reflect.DeepEqual(body.Error.Details, want) {
+		t.Errorf("got %s %+v, want VALIDATION_FAILED %+v", body.Error.Code, body.Error.Details, want)
+	}
+}
+
+func TestLookupsAcceptLegacyIDs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []fieldError
+	}{
+		{"legacy id/with slash", nil},
+		{"", []fieldError{{Field: "user_id", Reason: "is required"}}},
+		{strings.Repeat("a", maxLookupLength+1), []fieldError{{Field: "user_id", Reason: "must be at most 255 characters"}}},
+	}
+	for _, tt := range tests {
+		var v validator
+		v.lookup("user_id", tt.value)
+		if !reflect.DeepEqual(v.errors, tt.want) {
+			t.Errorf("lookup(%.20q): got %+v, want %+v", tt.value, v.errors, tt.want)
+		}
+	}
+
+	var v validator
+	v.id("user_id", "legacy id/with slash")
+	if len(v.errors) != 1 {
+		t.Errorf("id accepted an ID outside the pattern")
+	}
+
+	// Routes that act on an existing user or PR must get past validation
+	// with a legacy ID, so the store is asked for it and reports it missing
+	h := NewHandlers(service.NewService(notFoundStore{}, rand.NewSource(1), models.StrategyRandom))
+	legacy := `"legacy id/with slash"`
+	routes := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"setIsActive", h.SetUserActive, `{"user_id": ` + legacy + `, "is_active": false}`},
+		{"setOutOfOffice", h.SetUserOutOfOffice, `{"user_id": ` + legacy + `}`},
+		{"removeMember", h.RemoveTeamMember, `{"user_id": ` + legacy + `}`},
+		{"merge", h.MergePullRequest, `{"pull_request_id": ` + legacy + `}`},
+		{"reassign", h.ReassignReviewer, `{"pull_request_id": ` + legacy + `, "old_user_id": ` + legacy + `}`},
+		{"review", h.SubmitReview, `{"pull_request_id": ` + legacy + `, "reviewer_id": ` + legacy + `, "state": "APPROVED"}`},
+	}
+	for _, route := range routes {
+		w := httptest.NewRecorder()
+		route.handler(w, httptest.NewRequest("POST", "/", strings.NewReader(route.body)))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d %s, want %d", route.name, w.Code, w.Body.String(), http.StatusNotFound)
+		}
+	}
+}
+
+// notFoundStore has no users or PRs
+type notFoundStore struct {
+	service.Store
+}
+
+func (notFoundStore) GetUserByID(ctx context.Context, userID string) (models.User, error) {
+	return models.User{}, errors.New("user not found")
+}
+
+func (notFoundStore) UpdateUserActivity(ctx context.Context, userID string, isActive bool) (*models.User, error) {
+	return nil, errors.New("user not found")
+}
+
+func (notFoundStore) SetUserOutOfOffice(ctx context.Context, userID string, until *time.Time) (*models.User, error) {
+	return nil, errors.New("user not found")
+}
+
+func (notFoundStore) RemoveTeamMember(ctx context.Context, userID string) (*models.User, error) {
+	return nil, errors.New("user not found")
+}
+
+func (notFoundStore) GetPullRequest(ctx context.Context, prID string) (*models.PullRequest, error) {
+	return nil, errors.New("PR not found")
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   models.PageRequest
+		wantOK bool
+	}{
+		{"", models.PageRequest{}, true},
+		{"limit=50&cursor=pr-9", models.PageRequest{Limit: 50, Cursor: "pr-9"}, true},
+		{"limit=1000", models.PageRequest{Limit: 1000}, true},
+		{"limit=0", models.PageRequest{}, false},
+		{"limit=1001", models.PageRequest{}, false},
+		{"limit=ten", models.PageRequest{}, false},
+		{"cursor=" + strings.Repeat("a", maxLookupLength+1), models.PageRequest{}, false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		page, ok := parsePage(w, httptest.NewRequest("GET", "/tokens/list?"+tt.query, nil))
+		if ok != tt.wantOK || (ok && page != tt.want) {
+			t.Errorf("%q: got %+v, %v, want %+v, %v", tt.query, page, ok, tt.want, tt.wantOK)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"pr-reviewer-service/internal/metrics"
+	"pr-reviewer-service/internal/repository"
+	"pr-reviewer-service/internal/worker"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// checkTimeout bounds each readiness check so a hung dependency can't hang the probe
+const checkTimeout = 2 * time.Second
+
+// poolSaturatedRatio is the share of connections in use above which the
+// pool is reported degraded
+const poolSaturatedRatio = 0.9
+
+// Component statuses. A degraded component is reported but doesn't fail readiness.
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// Checker serves the liveness and readiness probes
+type Checker struct {
+	db       *sqlx.DB
+	repo     *repository.Repository
+	workers  *worker.Group
+	draining atomic.Bool
+}
+
+type componentStatus struct {
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+func NewChecker(db *sqlx.DB, repo *repository.Repository, workers *worker.Group) *Checker {
+	return &Checker{db: db, repo: repo, workers: workers}
+}
+
+// SetDraining makes readiness fail so load balancers stop routing new
+// requests here while in-flight ones finish
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Livez reports that the process is up and serving HTTP. It checks no
+// dependencies, so a database outage doesn't get the pod restarted.
+func (c *Checker) Livez(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, map[string]interface{}{"status": StatusOK})
+}
+
+// Readyz reports whether this instance should receive traffic, with the
+// status of each dependency
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	components := map[string]componentStatus{
+		"database":   c.checkDatabase(r.Context()),
+		"migrations": c.checkMigrations(r.Context()),
+		"workers":    c.checkWorkers(),
+		"pool":       c.checkPool(),
+	}
+	if c.draining.Load() {
+		components["shutdown"] = componentStatus{Status: StatusUnavailable, Error: "draining"}
+	}
+
+	status, code := StatusOK, http.StatusOK
+	for _, component := range components {
+		if component.Status == StatusUnavailable {
+			status, code = StatusUnavailable, http.StatusServiceUnavailable
+			break
+		}
+		if component.Status == StatusDegraded {
+			status = StatusDegraded
+		}
+	}
+
+	writeStatus(w, code, map[string]interface{}{
+		"status":     status,
+		"components": components,
+	})
+}
+
+func (c *Checker) checkDatabase(ctx context.Context) componentStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	if err := c.db.PingContext(ctx); err != nil {
+		return componentStatus{Status: StatusUnavailable, Error: err.Error()}
+	}
+	return componentStatus{Status: StatusOK}
+}
+
+// checkMigrations compares the schema version against the one this build
+// knows. InitSchema records SchemaVersion at startup, so a newer version can
+// only come from a newer build sharing the database, e.g. while a rollout is
+// rolled back. Older builds keep working against an additive schema, so this
+// is degraded rather than unavailable.
+func (c *Checker) checkMigrations(ctx context.Context) componentStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	version, err := c.repo.GetSchemaVersion(ctx)
+	if err != nil {
+		return componentStatus{Status: StatusUnavailable, Error: err.Error()}
+	}
+
+	detail := map[string]int{"applied": version, "supported": repository.SchemaVersion}
+	if version > repository.SchemaVersion {
+		return componentStatus{Status: StatusDegraded, Error: "database schema is newer than this build", Detail: detail}
+	}
+	return componentStatus{Status: StatusOK, Detail: detail}
+}
+
+// checkWorkers fails readiness only for critical workers. The others do
+// housekeeping or refresh caches that keep working while stale, and since
+// they often share a cause across replicas, such as the IdP being down,
+// failing readiness for them would take every replica out at once.
+func (c *Checker) checkWorkers() componentStatus {
+	statuses := c.workers.Status()
+	result := componentStatus{Status: StatusOK, Detail: statuses}
+	for _, st := range statuses {
+		if st.Healthy {
+			continue
+		}
+		if st.Critical {
+			return componentStatus{Status: StatusUnavailable, Error: "worker " + st.Name + " is not healthy", Detail: statuses}
+		}
+		if result.Status == StatusOK {
+			result = componentStatus{Status: StatusDegraded, Error: "worker " + st.Name + " is not healthy", Detail: statuses}
+		}
+	}
+	return result
+}
+
+// checkPool reports connection pool usage. A saturated pool makes requests
+// queue for connections, but taking the instance out of rotation would only
+// move the load elsewhere, so it is degraded rather than unavailable.
+func (c *Checker) checkPool() componentStatus {
+	stats := c.db.Stats()
+	saturation := metrics.PoolSaturation(stats)
+	detail := map[string]interface{}{
+		"max_open":      stats.MaxOpenConnections,
+		"open":          stats.OpenConnections,
+		"in_use":        stats.InUse,
+		"idle":          stats.Idle,
+		"wait_count":    stats.WaitCount,
+		"wait_duration": stats.WaitDuration.String(),
+		"saturation":    saturation,
+	}
+	if saturation >= poolSaturatedRatio {
+		return componentStatus{Status: StatusDegraded, Error: "connection pool saturated", Detail: detail}
+	}
+	return componentStatus{Status: StatusOK, Detail: detail}
+}
+
+func writeStatus(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
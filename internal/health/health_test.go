@@ -0,0 +1,40 @@
+package health
+
+import (
+	"context"
+	"pr-reviewer-service/internal/worker"
+	"testing"
+	"time"
+)
+
+func TestCheckWorkersFailsOnlyForCriticalWorkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		critical bool
+		want     string
+	}{
+		{"background worker", false, StatusDegraded},
+		{"critical worker", true, StatusUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workers := worker.NewGroup()
+			defer workers.Stop(context.Background())
+
+			workers.Go("healthy", time.Hour, func(ctx context.Context) { <-ctx.Done() })
+			stale := func(ctx context.Context) { <-ctx.Done() }
+			if tt.critical {
+				workers.GoCritical("stale", time.Millisecond, stale)
+			} else {
+				workers.Go("stale", time.Millisecond, stale)
+			}
+			time.Sleep(5 * time.Millisecond)
+
+			c := &Checker{workers: workers}
+			if got := c.checkWorkers(); got.Status != tt.want || got.Error != "worker stale is not healthy" {
+				t.Errorf("got %s %q, want %s", got.Status, got.Error, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteJSON writes data as the JSON response body with the given status
+func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// WriteError writes the API's error envelope:
+// {"error": {"code": ..., "message": ...}}
+func WriteError(w http.ResponseWriter, status int, code string, message string) {
+	WriteJSON(w, status, map[string]interface{}{
+		"error": map[string]string{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+// WriteErrorDetails writes the error envelope with a list of details, e.g.
+// the invalid fields of a request:
+// {"error": {"code": ..., "message": ..., "details": [...]}}
+func WriteErrorDetails(w http.ResponseWriter, status int, code string, message string, details interface{}) {
+	WriteJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"details": details,
+		},
+	})
+}
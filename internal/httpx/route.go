@@ -0,0 +1,37 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type routeKey struct{}
+
+// WithRoutes serves router behind middleware that wrap the whole router
+// rather than being attached with Use, so they also see the requests no
+// route matches (404 and 405). The route template is resolved once, before
+// the first middleware runs, and is available to all of them through Route.
+func WithRoutes(router *mux.Router, middleware ...func(http.Handler) http.Handler) http.Handler {
+	var handler http.Handler = router
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			route, _ = match.Route.GetPathTemplate()
+		}
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+	})
+}
+
+// Route returns the route template WithRoutes resolved for r, or an empty
+// string if no route matches
+func Route(r *http.Request) string {
+	route, _ := r.Context().Value(routeKey{}).(string)
+	return route
+}
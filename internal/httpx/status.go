@@ -0,0 +1,19 @@
+package httpx
+
+import "net/http"
+
+// StatusRecorder remembers the status code written through it, for
+// middleware that reports on the response after the handler ran
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (w *StatusRecorder) WriteHeader(status int) {
+	w.Status = status
+	w.ResponseWriter.WriteHeader(status)
+}
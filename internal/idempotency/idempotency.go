@@ -0,0 +1,200 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"pr-reviewer-service/internal/auth"
+	"pr-reviewer-service/internal/httpx"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/worker"
+	"time"
+)
+
+const (
+	// Header is the request header carrying the client's key
+	Header = "Idempotency-Key"
+
+	// ReplayedHeader is set on responses replayed from an earlier request
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+
+	// maxBodySize bounds the request bodies read for hashing
+	maxBodySize = 1 << 20
+
+	// pendingTTL is how long a key stays claimed by a request that hasn't
+	// finished, so a crashed request doesn't block retries until the TTL
+	pendingTTL = time.Minute
+)
+
+// Store keeps idempotency keys and the responses recorded for them
+type Store interface {
+	ClaimIdempotencyKey(ctx context.Context, scope, key, requestHash string, pendingTTL time.Duration) (*models.IdempotencyRecord, error)
+	SaveIdempotentResponse(ctx context.Context, scope, key string, response *models.IdempotencyRecord, ttl time.Duration) error
+	ReleaseIdempotencyKey(ctx context.Context, scope, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+// Middleware makes POST requests with an Idempotency-Key safe to retry.
+// The first request with a key runs and its response is kept for ttl;
+// retries with the same key and body get that response replayed, retries
+// with a different body get 422. Keys are scoped to the caller.
+type Middleware struct {
+	store Store
+	ttl   time.Duration
+}
+
+func NewMiddleware(store Store, ttl time.Duration) *Middleware {
+	return &Middleware{store: store, ttl: ttl}
+}
+
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			httpx.WriteError(w, http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY", "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+		if len(body) > maxBodySize {
+			httpx.WriteError(w, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", "request body is too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		scope := auth.ActorFromContext(ctx)
+		requestHash := hashRequest(r, body)
+
+		record, err := m.store.ClaimIdempotencyKey(ctx, scope, key, requestHash, pendingTTL)
+		if err != nil {
+			logger.Error("Failed to claim idempotency key", "error", err)
+			httpx.WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+			return
+		}
+		if record != nil {
+			replay(w, record, requestHash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// The response is already sent, so storing it must not depend on the
+		// client still being connected
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+
+		// Server errors may be transient; let the retry run again
+		if recorder.status >= http.StatusInternalServerError {
+			if err := m.store.ReleaseIdempotencyKey(storeCtx, scope, key); err != nil {
+				logger.Error("Failed to release idempotency key", "error", err)
+			}
+			return
+		}
+		header := recorder.Header()
+		err = m.store.SaveIdempotentResponse(storeCtx, scope, key, &models.IdempotencyRecord{
+			StatusCode:   &recorder.status,
+			ContentType:  header.Get("Content-Type"),
+			ETag:         header.Get("ETag"),
+			RetryAfter:   header.Get("Retry-After"),
+			ResponseBody: recorder.body.Bytes(),
+		}, m.ttl)
+		if err != nil {
+			logger.Error("Failed to store idempotent response", "error", err)
+		}
+	})
+}
+
+// DeleteExpired removes expired keys every interval until ctx is done.
+// It runs as a background worker.
+func (m *Middleware) DeleteExpired(ctx context.Context, interval time.Duration) {
+	logger := logging.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := m.store.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+			if ctx.Err() == nil {
+				logger.Error("Failed to delete expired idempotency keys", "error", err)
+			}
+		} else {
+			logger.Debug("Deleted expired idempotency keys", "count", deleted)
+			worker.Heartbeat(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func replay(w http.ResponseWriter, record *models.IdempotencyRecord, requestHash string) {
+	if record.RequestHash != requestHash {
+		httpx.WriteError(w, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED",
+			"Idempotency-Key was already used with a different request")
+		return
+	}
+	if record.StatusCode == nil {
+		w.Header().Set("Retry-After", "1")
+		httpx.WriteError(w, http.StatusConflict, "IDEMPOTENCY_IN_PROGRESS",
+			"a request with this Idempotency-Key is still in progress")
+		return
+	}
+
+	for name, value := range map[string]string{
+		"Content-Type": record.ContentType,
+		"ETag":         record.ETag,
+		"Retry-After":  record.RetryAfter,
+	} {
+		if value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(*record.StatusCode)
+	w.Write(record.ResponseBody)
+}
+
+// hashRequest identifies the request by method, path and body, so a key
+// reused for another endpoint counts as a different request
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes the response through and keeps a copy
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"pr-reviewer-service/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps keys in a map and never expires them
+type memoryStore struct {
+	records map[string]*models.IdempotencyRecord
+}
+
+func (m *memoryStore) ClaimIdempotencyKey(ctx context.Context, scope, key, requestHash string, pendingTTL time.Duration) (*models.IdempotencyRecord, error) {
+	if record, ok := m.records[scope+"/"+key]; ok {
+		return record, nil
+	}
+	m.records[scope+"/"+key] = &models.IdempotencyRecord{RequestHash: requestHash}
+	return nil, nil
+}
+
+func (m *memoryStore) SaveIdempotentResponse(ctx context.Context, scope, key string, response *models.IdempotencyRecord, ttl time.Duration) error {
+	saved := *response
+	saved.RequestHash = m.records[scope+"/"+key].RequestHash
+	m.records[scope+"/"+key] = &saved
+	return nil
+}
+
+func (m *memoryStore) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	delete(m.records, scope+"/"+key)
+	return nil
+}
+
+func (m *memoryStore) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestReplayKeepsHeaders(t *testing.T) {
+	runs := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"2"`)
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("X-Request-Id", "not replayed")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"pr": {}}`))
+	})
+	m := NewMiddleware(&memoryStore{records: map[string]*models.IdempotencyRecord{}}, time.Hour)
+
+	var responses []*httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("POST", "/pullRequest/create", strings.NewReader(`{"pull_request_id": "pr-1"}`))
+		r.Header.Set(Header, "key-1")
+		w := httptest.NewRecorder()
+		m.Handler(handler).ServeHTTP(w, r)
+		responses = append(responses, w)
+	}
+
+	if runs != 1 {
+		t.Fatalf("handler ran %d times", runs)
+	}
+	replayed := responses[1]
+	if replayed.Header().Get(ReplayedHeader) != "true" || replayed.Code != http.StatusCreated || replayed.Body.String() != `{"pr": {}}` {
+		t.Errorf("unexpected replay %d %v %s", replayed.Code, replayed.Header(), replayed.Body)
+	}
+	for name, want := range map[string]string{
+		"Content-Type": "application/json",
+		"ETag":         `"2"`,
+		"Retry-After":  "30",
+		"X-Request-Id": "",
+	} {
+		if got := replayed.Header().Get(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"pr-reviewer-service/internal/httpx"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey struct{}
+
+// level is shared by every logger New returns, so SetLevel affects them all
+var level slog.LevelVar
+
+// New returns a JSON logger writing to stdout at the given level
+// ("debug", "info", "warn" or "error"; anything else means info)
+func New(name string) *slog.Logger {
+	SetLevel(name)
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &level}))
+}
+
+// SetLevel changes the level of loggers returned by New while they run
+func SetLevel(name string) {
+	switch strings.ToLower(name) {
+	case "debug":
+		level.Set(slog.LevelDebug)
+	case "warn":
+		level.Set(slog.LevelWarn)
+	case "error":
+		level.Set(slog.LevelError)
+	default:
+		level.Set(slog.LevelInfo)
+	}
+}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Middleware assigns each request an ID, or keeps the one the client sent in
+// X-Request-ID, echoes it in the response and puts a logger tagged with it into
+// the request context. When the request completes it is logged with its route,
+// status and duration.
+func Middleware(base *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" || len(requestID) > 128 {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			route := httpx.Route(r)
+
+			logger := base.With("request_id", requestID)
+			if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+				logger = logger.With("trace_id", spanContext.TraceID().String())
+			}
+			start := time.Now()
+			sw := httpx.NewStatusRecorder(w)
+			next.ServeHTTP(sw, r.WithContext(WithLogger(r.Context(), logger)))
+
+			logger.Info("request",
+				"method", r.Method,
+				"route", route,
+				"status", sw.Status,
+				"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			)
+		})
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"pr-reviewer-service/internal/httpx"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMiddlewareLogsUnmatchedRequests(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/team/get", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	tests := []struct {
+		method, path string
+		route        string
+		status       int
+	}{
+		{"GET", "/team/get", "/team/get", http.StatusOK},
+		{"GET", "/team/missing", "", http.StatusNotFound},
+		{"POST", "/team/get", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		handler := httpx.WithRoutes(r, Middleware(slog.New(slog.NewJSONHandler(&logs, nil))))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		var line struct {
+			RequestID string `json:"request_id"`
+			Route     string `json:"route"`
+			Status    int    `json:"status"`
+		}
+		if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+			t.Fatalf("%s %s: no request log line: %q", tt.method, tt.path, logs.String())
+		}
+		if line.RequestID == "" || line.RequestID != w.Header().Get(RequestIDHeader) {
+			t.Errorf("%s %s: logged request ID %q, header %q", tt.method, tt.path, line.RequestID, w.Header().Get(RequestIDHeader))
+		}
+		if line.Route != tt.route || line.Status != tt.status {
+			t.Errorf("%s %s: logged %q %d, want %q %d", tt.method, tt.path, line.Route, line.Status, tt.route, tt.status)
+		}
+	}
+}
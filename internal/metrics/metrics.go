@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"pr-reviewer-service/internal/httpx"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/worker"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "pr_reviewer"
+
+// Registry holds every metric the service exposes on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Repository operation latency by operation and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "outcome"})
+
+	openPullRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_pull_requests",
+		Help:      "Open PRs by the author's team.",
+	}, []string{"team"})
+
+	openReviews = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_reviews",
+		Help:      "Open PRs each user is assigned to review.",
+	}, []string{"user_id"})
+
+	rateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected by the rate limiter by route.",
+	}, []string{"route"})
+
+	understaffedPullRequests = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "understaffed_pull_requests",
+		Help:      "Open PRs that got fewer reviewers than requested.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbQueryDuration,
+		rateLimitedRequests,
+		openPullRequests,
+		openReviews,
+		understaffedPullRequests,
+	)
+}
+
+// RegisterDB exposes the connection pool stats of db, and the share of the
+// pool in use so saturation can be alerted on directly
+func RegisterDB(db *sqlx.DB) {
+	Registry.MustRegister(
+		collectors.NewDBStatsCollector(db.DB, "postgres"),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "db_pool_saturation_ratio",
+			Help:      "Connections in use divided by the maximum open connections; 0 when the pool is unbounded.",
+		}, func() float64 {
+			return PoolSaturation(db.Stats())
+		}),
+	)
+}
+
+// PoolSaturation returns the share of the pool's connections in use,
+// or 0 if the pool has no limit
+func PoolSaturation(stats sql.DBStats) float64 {
+	if stats.MaxOpenConnections <= 0 {
+		return 0
+	}
+	return float64(stats.InUse) / float64(stats.MaxOpenConnections)
+}
+
+// Handler serves Registry in the Prometheus text exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records request counts and latency labeled with the mux route
+// template, so path parameters don't blow up label cardinality. It runs
+// under httpx.WithRoutes, so requests no route matches (404 and 405) are
+// counted too, labeled "unknown".
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := httpx.Route(r)
+		if route == "" {
+			route = "unknown"
+		}
+
+		start := time.Now()
+		sw := httpx.NewStatusRecorder(w)
+		next.ServeHTTP(sw, r)
+
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(sw.Status)).Inc()
+	})
+}
+
+// ObserveQuery records how long a repository operation took
+func ObserveQuery(operation string, start time.Time, err error) {
+	outcome := "success"
+	if errors.Is(err, context.DeadlineExceeded) {
+		outcome = "timeout"
+	} else if err != nil {
+		outcome = "error"
+	}
+	dbQueryDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// ObserveRateLimited counts a request rejected by the rate limiter
+func ObserveRateLimited(route string) {
+	rateLimitedRequests.WithLabelValues(route).Inc()
+}
+
+// DomainSource provides the values of the domain gauges
+type DomainSource interface {
+	GetDomainMetrics(ctx context.Context) (*models.DomainMetrics, error)
+}
+
+// RefreshDomainGauges recomputes the domain gauges every interval until ctx
+// is done. The gauges come from aggregate queries, so they are refreshed in
+// the background rather than on every scrape.
+func RefreshDomainGauges(ctx context.Context, source DomainSource, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshDomainGauges(ctx, source)
+		worker.Heartbeat(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func refreshDomainGauges(ctx context.Context, source DomainSource) {
+	snapshot, err := source.GetDomainMetrics(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Failed to refresh domain metrics", "error", err)
+		}
+		return
+	}
+
+	// Reset so teams and users that no longer have open PRs disappear
+	openPullRequests.Reset()
+	for team, count := range snapshot.OpenPullRequestsByTeam {
+		openPullRequests.WithLabelValues(team).Set(float64(count))
+	}
+	openReviews.Reset()
+	for userID, count := range snapshot.OpenReviewsByUser {
+		openReviews.WithLabelValues(userID).Set(float64(count))
+	}
+	understaffedPullRequests.Set(float64(snapshot.UnderstaffedPullRequests))
+}
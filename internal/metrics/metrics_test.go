@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"pr-reviewer-service/internal/httpx"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareCountsUnmatchedRequests(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/team/get", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	handler := httpx.WithRoutes(r, Middleware)
+
+	tests := []struct {
+		method, path string
+		route        string
+		status       string
+	}{
+		{"GET", "/team/get?team_name=backend", "/team/get", "200"},
+		{"GET", "/team/missing", "unknown", "404"},
+		{"POST", "/team/get", "unknown", "405"},
+	}
+
+	for _, tt := range tests {
+		counter := httpRequests.WithLabelValues(tt.route, tt.method, tt.status)
+		before := testutil.ToFloat64(counter)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+		if got := testutil.ToFloat64(counter) - before; got != 1 {
+			t.Errorf("%s %s: counted %v requests as %s %s, want 1", tt.method, tt.path, got, tt.route, tt.status)
+		}
+	}
+}
@@ -5,10 +5,33 @@ import (
 )
 
 type User struct {
-	UserID   string `json:"user_id" db:"user_id"`
-	Username string `json:"username" db:"username"`
-	TeamName string `json:"team_name" db:"team_name"`
-	IsActive bool   `json:"is_active" db:"is_active"`
+	UserID         string  `json:"user_id" db:"user_id"`
+	Username       string  `json:"username" db:"username"`
+	TeamName       string  `json:"team_name" db:"team_name"`
+	IsActive       bool    `json:"is_active" db:"is_active"`
+	ReviewWeight   float64 `json:"review_weight" db:"review_weight"`
+	MaxOpenReviews *int    `json:"max_open_reviews,omitempty" db:"max_open_reviews"`
+
+	OutOfOfficeUntil *time.Time `json:"out_of_office_until,omitempty" db:"out_of_office_until"`
+	// RemovedAt is set once the user is removed from their team
+	RemovedAt *time.Time `json:"removed_at,omitempty" db:"removed_at"`
+}
+
+// MemberChange adds a user to a team. Nil fields keep an existing user's
+// value; a new user gets the defaults: active, weight 1 and no review cap.
+type MemberChange struct {
+	UserID         string   `json:"user_id"`
+	Username       string   `json:"username"`
+	TeamName       string   `json:"team_name"`
+	IsActive       *bool    `json:"is_active,omitempty"`
+	ReviewWeight   *float64 `json:"review_weight,omitempty"`
+	MaxOpenReviews *int     `json:"max_open_reviews,omitempty"`
+}
+
+// TeamMember is a user together with the number of open PRs they review
+type TeamMember struct {
+	User
+	OpenReviews int `db:"open_reviews"`
 }
 
 type Team struct {
@@ -16,14 +39,74 @@ type Team struct {
 	Members  []User `json:"members"`
 }
 
+// TeamPolicy overrides service-wide reviewer selection settings for a team
+type TeamPolicy struct {
+	TeamName string `json:"team_name" db:"team_name"`
+	Strategy string `json:"strategy" db:"strategy"`
+
+	// ReviewerCount is how many reviewers each new PR gets
+	ReviewerCount int `json:"reviewer_count" db:"reviewer_count"`
+
+	// Reviewers of the author's last AffinityWindow PRs have their weight
+	// multiplied by AffinityPenalty once per such PR; a zero window disables it
+	AffinityWindow  int     `json:"affinity_window" db:"affinity_window"`
+	AffinityPenalty float64 `json:"affinity_penalty" db:"affinity_penalty"`
+}
+
 type PullRequest struct {
-	PullRequestID     string     `json:"pull_request_id" db:"pull_request_id"`
-	PullRequestName   string     `json:"pull_request_name" db:"pull_request_name"`
-	AuthorID          string     `json:"author_id" db:"author_id"`
-	Status            string     `json:"status" db:"status"`
-	AssignedReviewers []string   `json:"assigned_reviewers" db:"assigned_reviewers"`
-	CreatedAt         time.Time  `json:"createdAt" db:"created_at"`
-	MergedAt          *time.Time `json:"mergedAt" db:"merged_at"`
+	PullRequestID     string   `json:"pull_request_id" db:"pull_request_id"`
+	PullRequestName   string   `json:"pull_request_name" db:"pull_request_name"`
+	AuthorID          string   `json:"author_id" db:"author_id"`
+	Status            string   `json:"status" db:"status"`
+	AssignedReviewers []string `json:"assigned_reviewers" db:"assigned_reviewers"`
+	// RequestedReviewers is how many reviewers selection aimed for;
+	// AssignedReviewers is shorter when the team had too few candidates
+	RequestedReviewers int        `json:"requested_reviewers" db:"requested_reviewers"`
+	CreatedAt          time.Time  `json:"createdAt" db:"created_at"`
+	MergedAt           *time.Time `json:"mergedAt" db:"merged_at"`
+
+	// Strategy and seed the reviewers were picked with, enough to replay the selection
+	AssignmentStrategy string `json:"assignment_strategy" db:"assignment_strategy"`
+	AssignmentSeed     int64  `json:"assignment_seed" db:"assignment_seed"`
+
+	// Version is incremented by every update, which only applies if the
+	// PR is still at the version it was read at
+	Version int64 `json:"version" db:"version"`
+}
+
+// Reviewer selection strategies
+const (
+	// StrategyRandom draws a fresh seed from the service's random source
+	StrategyRandom = "random"
+	// StrategyPRSeeded derives the seed from the PR ID, so the same inputs
+	// always produce the same reviewers
+	StrategyPRSeeded = "pr_seeded"
+	// StrategyRoundRobin walks the team in user_id order using a cursor
+	// persisted per team; it doesn't use a seed
+	StrategyRoundRobin = "round_robin"
+)
+
+func IsValidStrategy(strategy string) bool {
+	switch strategy {
+	case StrategyRandom, StrategyPRSeeded, StrategyRoundRobin:
+		return true
+	}
+	return false
+}
+
+// Review states
+const (
+	ReviewCommented        = "COMMENTED"
+	ReviewApproved         = "APPROVED"
+	ReviewChangesRequested = "CHANGES_REQUESTED"
+)
+
+type Review struct {
+	ReviewID      int64     `json:"review_id" db:"id"`
+	PullRequestID string    `json:"pull_request_id" db:"pull_request_id"`
+	ReviewerID    string    `json:"reviewer_id" db:"reviewer_id"`
+	State         string    `json:"state" db:"state"`
+	SubmittedAt   time.Time `json:"submittedAt" db:"submitted_at"`
 }
 
 type PullRequestShort struct {
@@ -32,3 +115,151 @@ type PullRequestShort struct {
 	AuthorID        string `json:"author_id"`
 	Status          string `json:"status"`
 }
+
+// Unassignment records who took a reviewer off a PR and why
+type Unassignment struct {
+	// Actor is empty when authentication is disabled
+	Actor  string
+	Reason string
+	// Declined is set when reviewers removed themselves
+	Declined bool
+	// DeclineLimit, if not zero, is how many assignments the reviewer may
+	// have declined since DeclineSince, this one included
+	DeclineLimit int
+	DeclineSince time.Time
+}
+
+// Replacement takes one reviewer off a PR in favour of another
+type Replacement struct {
+	OldUserID    string
+	NewUserID    string
+	Strategy     string
+	Seed         int64
+	Unassignment Unassignment
+}
+
+// API token roles
+const (
+	RoleAdmin    = "admin"
+	RoleTeamLead = "team-lead"
+	RoleBot      = "bot"
+	RoleReader   = "reader"
+)
+
+// APIToken describes an issued token; the token itself is only stored hashed.
+// TeamName is set for team-lead tokens and scopes them to that team. UserID
+// links the token to a user it acts as, e.g. to decline their reviews.
+type APIToken struct {
+	TokenID   string     `json:"token_id" db:"token_id"`
+	Name      string     `json:"name" db:"name"`
+	Role      string     `json:"role" db:"role"`
+	TeamName  string     `json:"team_name,omitempty" db:"team_name"`
+	UserID    string     `json:"user_id,omitempty" db:"user_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// IssuedToken is returned once, when a token is issued
+type IssuedToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key. StatusCode is nil while the request is in progress.
+// Empty headers weren't set on the response.
+type IdempotencyRecord struct {
+	RequestHash  string `db:"request_hash"`
+	StatusCode   *int   `db:"status_code"`
+	ContentType  string `db:"content_type"`
+	ETag         string `db:"etag"`
+	RetryAfter   string `db:"retry_after"`
+	ResponseBody []byte `db:"response_body"`
+}
+
+// Reasons a team member is not a reviewer candidate
+const (
+	ExclusionAuthor      = "AUTHOR"
+	ExclusionInactive    = "INACTIVE"
+	ExclusionOutOfOffice = "OUT_OF_OFFICE"
+	ExclusionAtCapacity  = "AT_CAPACITY"
+)
+
+type CandidateExclusion struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+// AssignmentPreview describes the reviewers CreatePullRequest would pick
+type AssignmentPreview struct {
+	PullRequestID string               `json:"pull_request_id"`
+	AuthorID      string               `json:"author_id"`
+	Reviewers     []string             `json:"reviewers"`
+	Requested     int                  `json:"requested_reviewers"`
+	Candidates    []string             `json:"candidates"`
+	Excluded      []CandidateExclusion `json:"excluded"`
+	Strategy      string               `json:"strategy"`
+	Seed          int64                `json:"seed"`
+}
+
+// PageRequest asks for up to Limit items of a list, starting after the item
+// whose ID is Cursor; a zero Limit asks for all of them
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// StatsFilter narrows statistics to a team and a [From, To) time range
+type StatsFilter struct {
+	TeamName string
+	From     *time.Time
+	To       *time.Time
+}
+
+type ReviewerStats struct {
+	UserID         string `json:"user_id" db:"user_id"`
+	Username       string `json:"username" db:"username"`
+	TeamName       string `json:"team_name" db:"team_name"`
+	Assignments    int    `json:"assignments" db:"assignments"`
+	OpenReviews    int    `json:"open_reviews" db:"open_reviews"`
+	MergedReviews  int    `json:"merged_reviews" db:"merged_reviews"`
+	ReassignedAway int    `json:"reassigned_away" db:"reassigned_away"`
+}
+
+type PullRequestStats struct {
+	Total                    int            `json:"total"`
+	ByStatus                 map[string]int `json:"by_status"`
+	MedianTimeToMergeSeconds *float64       `json:"median_time_to_merge_seconds"`
+}
+
+// Percentiles of a duration distribution in seconds; nil when no PR
+// in the bucket reached that point yet
+type Percentiles struct {
+	P50 *float64 `json:"p50"`
+	P90 *float64 `json:"p90"`
+	P99 *float64 `json:"p99"`
+}
+
+// CycleTimeBucket holds cycle time distributions for PRs created in one week,
+// grouped either by the author's team or by assigned reviewer
+type CycleTimeBucket struct {
+	TeamName          string      `json:"team_name,omitempty"`
+	ReviewerID        string      `json:"reviewer_id,omitempty"`
+	Week              time.Time   `json:"week"`
+	PullRequests      int         `json:"pull_requests"`
+	TimeToFirstReview Percentiles `json:"time_to_first_review_seconds"`
+	TimeToApproval    Percentiles `json:"time_to_approval_seconds"`
+	TimeToMerge       Percentiles `json:"time_to_merge_seconds"`
+}
+
+type CycleTimeStats struct {
+	Teams     []CycleTimeBucket `json:"teams"`
+	Reviewers []CycleTimeBucket `json:"reviewers"`
+}
+
+// DomainMetrics is a snapshot of the values behind the domain gauges
+type DomainMetrics struct {
+	OpenPullRequestsByTeam   map[string]int
+	OpenReviewsByUser        map[string]int
+	UnderstaffedPullRequests int
+}
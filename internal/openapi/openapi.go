@@ -0,0 +1,61 @@
+// Package openapi serves the service's OpenAPI document and a Swagger UI
+// page for browsing it
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// SpecPath is where the document is served; the UI loads it from there
+const SpecPath = "/openapi.json"
+
+//go:embed openapi.json
+var spec []byte
+
+// uiPage replaces the bundled index.html, which points at a demo document
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>PR Reviewer Assignment Service API</title>
+  <link rel="stylesheet" href="swagger-ui.css">
+  <link rel="icon" type="image/png" href="favicon-32x32.png" sizes="32x32">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "` + SpecPath + `", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// Spec returns the OpenAPI document
+func Spec() []byte {
+	return spec
+}
+
+// SpecHandler serves the OpenAPI document
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// UIHandler serves Swagger UI under prefix, which must end with a slash
+func UIHandler(prefix string) http.Handler {
+	assets := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		if name == "" || name == "index.html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(uiPage))
+			return
+		}
+		assets.ServeHTTP(w, r)
+	})
+}
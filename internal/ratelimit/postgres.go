@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"pr-reviewer-service/internal/worker"
+	"time"
+)
+
+// BucketStore keeps token buckets in a shared database
+type BucketStore interface {
+	// TakeRateLimitToken refills the bucket, takes a token if there is one
+	// and returns whether it did and how many tokens are left
+	TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (bool, float64, error)
+	DeleteIdleRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// PostgresLimiter keeps buckets in Postgres, so all replicas share one budget
+type PostgresLimiter struct {
+	store BucketStore
+}
+
+func NewPostgresLimiter(store BucketStore) *PostgresLimiter {
+	return &PostgresLimiter{store: store}
+}
+
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	allowed, tokens, err := l.store.TakeRateLimitToken(ctx, key, limit.Rate, limit.Burst)
+	if err != nil {
+		return Decision{}, err
+	}
+	if !allowed {
+		return Decision{RetryAfter: retryAfter(tokens, limit.Rate)}, nil
+	}
+	return Decision{Allowed: true}, nil
+}
+
+// DeleteIdleBuckets removes buckets unused for idle every interval until
+// ctx is done. It runs as a background worker.
+func (l *PostgresLimiter) DeleteIdleBuckets(ctx context.Context, idle, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := l.store.DeleteIdleRateLimitBuckets(ctx, idle); err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Failed to delete idle rate limit buckets", "error", err)
+			}
+		} else {
+			slog.Debug("Deleted idle rate limit buckets", "count", deleted)
+			worker.Heartbeat(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"pr-reviewer-service/internal/auth"
+	"pr-reviewer-service/internal/httpx"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst.
+// A zero Rate means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of taking a token
+type Decision struct {
+	Allowed bool
+	// RetryAfter is how long until a token is available, when not allowed
+	RetryAfter time.Duration
+}
+
+// Limiter takes a token from the bucket named key
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// Middleware limits requests per route and client. Clients are told apart
+// by their authenticated principal, or by IP address without one. Behind
+// proxies the IP is read from clientIPHeader, which each proxy appends the
+// address it got the request from to; the entry trustedProxies from the
+// right is the one the outermost trusted proxy added, and entries left of it
+// are whatever the client sent. Probes and metrics are never limited.
+type Middleware struct {
+	limiter        Limiter
+	defaultLimit   Limit
+	routeLimits    map[string]Limit
+	ipLimit        Limit
+	clientIPHeader string
+	trustedProxies int
+}
+
+func NewMiddleware(limiter Limiter, defaultLimit Limit, routeLimits map[string]Limit, ipLimit Limit, clientIPHeader string, trustedProxies int) *Middleware {
+	return &Middleware{
+		limiter:        limiter,
+		defaultLimit:   defaultLimit,
+		routeLimits:    routeLimits,
+		ipLimit:        ipLimit,
+		clientIPHeader: clientIPHeader,
+		trustedProxies: trustedProxies,
+	}
+}
+
+// Handler rejects requests over the route's limit for their client with
+// 429 and a Retry-After header. It runs after authentication, so clients
+// with a token are limited by it rather than by IP.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		limit, ok := m.routeLimits[route]
+		if !ok {
+			limit = m.defaultLimit
+		}
+		m.limit(w, r, next, route, route+"|"+m.clientKey(r), limit)
+	})
+}
+
+// IPHandler rejects requests over ipLimit per IP address across all
+// routes. It runs before authentication, so requests with missing or
+// invalid credentials are limited as well.
+func (m *Middleware) IPHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		m.limit(w, r, next, route, "ip|"+m.clientIP(r), m.ipLimit)
+	})
+}
+
+// limit takes a token from the bucket named key and passes the request on
+// if it got one. If the limiter fails the request is let through: an
+// outage of the shared limiter shouldn't take the API down with it.
+func (m *Middleware) limit(w http.ResponseWriter, r *http.Request, next http.Handler, route, key string, limit Limit) {
+	if auth.IsPublicRoute(route) || limit.Rate <= 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	decision, err := m.limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Rate limiter failed, allowing request", "error", err)
+		next.ServeHTTP(w, r)
+		return
+	}
+	if !decision.Allowed {
+		metrics.ObserveRateLimited(route)
+		seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		httpx.WriteError(w, http.StatusTooManyRequests, "RATE_LIMITED", "rate limit exceeded, retry after "+strconv.Itoa(seconds)+"s")
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		route, _ := current.GetPathTemplate()
+		return route
+	}
+	return ""
+}
+
+func (m *Middleware) clientKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Actor()
+	}
+	return "ip:" + m.clientIP(r)
+}
+
+func (m *Middleware) clientIP(r *http.Request) string {
+	if m.clientIPHeader != "" && m.trustedProxies > 0 {
+		if values := r.Header.Values(m.clientIPHeader); len(values) > 0 {
+			entries := strings.Split(strings.Join(values, ","), ",")
+			// With fewer entries than trusted proxies, all of them were
+			// added by proxies
+			i := len(entries) - m.trustedProxies
+			if i < 0 {
+				i = 0
+			}
+			if ip := strings.TrimSpace(entries[i]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfter returns how long until tokens reach one at the given rate
+func retryAfter(tokens float64, rate float64) time.Duration {
+	return time.Duration((1 - tokens) / rate * float64(time.Second))
+}
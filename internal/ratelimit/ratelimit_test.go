@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestClientIPTrustsOnlyProxyEntries(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		forwardedFor   []string
+		trustedProxies int
+		want           string
+	}{
+		{"no header configured", "", []string{"1.1.1.1"}, 1, "10.0.0.1"},
+		{"header missing", "X-Forwarded-For", nil, 1, "10.0.0.1"},
+		{"one proxy", "X-Forwarded-For", []string{"9.9.9.9"}, 1, "9.9.9.9"},
+		{"spoofed entry ignored", "X-Forwarded-For", []string{"6.6.6.6, 9.9.9.9"}, 1, "9.9.9.9"},
+		{"two proxies", "X-Forwarded-For", []string{"6.6.6.6, 9.9.9.9, 172.16.0.1"}, 2, "9.9.9.9"},
+		{"repeated headers", "X-Forwarded-For", []string{"6.6.6.6", "9.9.9.9"}, 1, "9.9.9.9"},
+		{"fewer entries than proxies", "X-Forwarded-For", []string{"9.9.9.9"}, 2, "9.9.9.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMiddleware(nil, Limit{}, nil, Limit{}, tt.header, tt.trustedProxies)
+			r := httptest.NewRequest("GET", "/team/get", nil)
+			r.RemoteAddr = "10.0.0.1:4321"
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := m.clientIP(r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPHandlerLimitsBeforeAuthentication(t *testing.T) {
+	m := NewMiddleware(NewMemoryLimiter(), Limit{}, nil, Limit{Rate: 0.001, Burst: 1}, "", 0)
+	unauthorized := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	router := mux.NewRouter()
+	router.Handle("/team/get", unauthorized)
+	router.Use(m.IPHandler)
+
+	var statuses []int
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/team/get", nil)
+		r.RemoteAddr = "10.0.0.1:4321"
+		router.ServeHTTP(w, r)
+		statuses = append(statuses, w.Code)
+	}
+	if statuses[0] != http.StatusUnauthorized || statuses[1] != http.StatusTooManyRequests {
+		t.Errorf("expected 401 then 429, got %v", statuses)
+	}
+}
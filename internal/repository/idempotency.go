@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pr-reviewer-service/internal/models"
+	"time"
+)
+
+// ClaimIdempotencyKey reserves the key for a request with the given hash
+// until pendingTTL passes, unless another unexpired request holds it. It
+// returns nil if the key was claimed, or the record of the request that
+// holds it.
+func (r *Repository) ClaimIdempotencyKey(ctx context.Context, scope, key, requestHash string, pendingTTL time.Duration) (*models.IdempotencyRecord, error) {
+	// The row can expire or be deleted between the two statements; one more
+	// attempt then claims it
+	for attempt := 0; attempt < 2; attempt++ {
+		var claimed string
+		err := r.getContext(ctx, r.db, "ClaimIdempotencyKey", &claimed, `
+			INSERT INTO idempotency_keys AS k (scope, idempotency_key, request_hash, expires_at)
+			VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+			ON CONFLICT (scope, idempotency_key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL,
+			    etag = NULL, retry_after = NULL, response_body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at
+			WHERE k.expires_at < now()
+			RETURNING idempotency_key`,
+			scope, key, requestHash, pendingTTL.Seconds())
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		var record models.IdempotencyRecord
+		err = r.getContext(ctx, r.db, "ClaimIdempotencyKey", &record, `
+			SELECT request_hash, status_code, COALESCE(content_type, '') AS content_type,
+			       COALESCE(etag, '') AS etag, COALESCE(retry_after, '') AS retry_after, response_body
+			FROM idempotency_keys
+			WHERE scope = $1 AND idempotency_key = $2 AND expires_at >= now()`,
+			scope, key)
+		if err == nil {
+			return &record, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return nil, errors.New("idempotency key is contended")
+}
+
+// SaveIdempotentResponse stores the response of the request holding the
+// key and keeps it for ttl. The response's RequestHash is not stored.
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, scope, key string, response *models.IdempotencyRecord, ttl time.Duration) error {
+	_, err := r.execContext(ctx, r.db, "SaveIdempotentResponse", `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = NULLIF($4, ''), etag = NULLIF($5, ''), retry_after = NULLIF($6, ''),
+		    response_body = $7, expires_at = now() + make_interval(secs => $8)
+		WHERE scope = $1 AND idempotency_key = $2`,
+		scope, key, response.StatusCode, response.ContentType, response.ETag, response.RetryAfter,
+		response.ResponseBody, ttl.Seconds())
+	return err
+}
+
+// ReleaseIdempotencyKey deletes the key so the request can be retried
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	_, err := r.execContext(ctx, r.db, "ReleaseIdempotencyKey",
+		"DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2", scope, key)
+	return err
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	res, err := r.execContext(ctx, r.db, "DeleteExpiredIdempotencyKeys",
+		"DELETE FROM idempotency_keys WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
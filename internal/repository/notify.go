@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// TeamPoliciesChannel is notified with the team name whenever a row of
+// team_policies is inserted, updated or deleted
+const TeamPoliciesChannel = "team_policies_changed"
+
+// ListenTeamPolicyChanges holds a connection listening on TeamPoliciesChannel
+// until ctx is done or the connection fails. wake is called with true once
+// listening starts, so callers can catch up on changes they may have missed,
+// and after every notification; it is called with false when idle passes
+// without one.
+func (r *Repository) ListenTeamPolicyChanges(ctx context.Context, idle time.Duration, wake func(notified bool)) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) (err error) {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+
+		listen := "LISTEN " + TeamPoliciesChannel
+		queryCtx, finish := startQuery(ctx, "ListenTeamPolicyChanges", listen)
+		_, err = pgxConn.Exec(queryCtx, listen)
+		finish(err)
+		if err != nil {
+			return err
+		}
+
+		// The connection goes back to the pool afterwards; make sure it
+		// doesn't keep listening, or discard it if that can't be done
+		defer func() {
+			unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, unlistenErr := pgxConn.Exec(unlistenCtx, "UNLISTEN *"); unlistenErr != nil {
+				err = fmt.Errorf("%w: %w", driver.ErrBadConn, errors.Join(err, unlistenErr))
+			}
+		}()
+
+		wake(true)
+		for {
+			waitCtx, cancel := context.WithTimeout(ctx, idle)
+			_, err := pgxConn.WaitForNotification(waitCtx)
+			cancel()
+
+			switch {
+			case err == nil:
+				wake(true)
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case errors.Is(err, context.DeadlineExceeded):
+				wake(false)
+			default:
+				return err
+			}
+		}
+	})
+}
@@ -6,20 +6,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"pr-reviewer-service/internal/auth"
 	"pr-reviewer-service/internal/models"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type Repository struct {
 	db *sqlx.DB
+
+	// queryTimeout bounds each statement that runs on db, and each
+	// transaction as a whole; zero means no limit beyond the caller's context
+	queryTimeout time.Duration
 }
 
-func NewRepository(db *sqlx.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sqlx.DB, queryTimeout time.Duration) *Repository {
+	return &Repository{db: db, queryTimeout: queryTimeout}
 }
 
 func (r *Repository) CreateTeam(ctx context.Context, team *models.Team) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -28,7 +37,7 @@ func (r *Repository) CreateTeam(ctx context.Context, team *models.Team) error {
 
 	// Check if team already exists
 	var existingTeam string
-	err = tx.GetContext(ctx, &existingTeam,
+	err = r.getContext(ctx, tx, "CreateTeam", &existingTeam,
 		"SELECT team_name FROM teams WHERE team_name = $1", team.TeamName)
 	if err == nil {
 		return fmt.Errorf("team already exists")
@@ -37,7 +46,7 @@ func (r *Repository) CreateTeam(ctx context.Context, team *models.Team) error {
 	}
 
 	// Insert team
-	_, err = tx.ExecContext(ctx,
+	_, err = r.execContext(ctx, tx, "CreateTeam",
 		"INSERT INTO teams (team_name) VALUES ($1)", team.TeamName)
 	if err != nil {
 		return err
@@ -45,12 +54,14 @@ func (r *Repository) CreateTeam(ctx context.Context, team *models.Team) error {
 
 	// Insert/update users
 	for _, member := range team.Members {
-		_, err = tx.ExecContext(ctx, `
-			INSERT INTO users (user_id, username, team_name, is_active) 
-			VALUES ($1, $2, $3, $4)
+		_, err = r.execContext(ctx, tx, "CreateTeam", `
+			INSERT INTO users (user_id, username, team_name, is_active, review_weight, max_open_reviews, updated_by) 
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
 			ON CONFLICT (user_id) 
-			DO UPDATE SET username = $2, team_name = $3, is_active = $4`,
-			member.UserID, member.Username, team.TeamName, member.IsActive)
+			DO UPDATE SET username = $2, team_name = $3, is_active = $4, review_weight = $5, max_open_reviews = $6,
+				removed_at = NULL, updated_at = CURRENT_TIMESTAMP, updated_by = NULLIF($7, '')`,
+			member.UserID, member.Username, team.TeamName, member.IsActive, member.ReviewWeight, member.MaxOpenReviews,
+			auth.ActorFromContext(ctx))
 		if err != nil {
 			return err
 		}
@@ -59,10 +70,38 @@ func (r *Repository) CreateTeam(ctx context.Context, team *models.Team) error {
 	return tx.Commit()
 }
 
+// AddTeamMember adds member to their team_name, moving them there if they
+// are in another team or bringing them back if they were removed. Of an
+// existing user, only the fields member sets are changed.
+func (r *Repository) AddTeamMember(ctx context.Context, member *models.MemberChange) (*models.User, error) {
+	var user models.User
+	err := r.getContext(ctx, r.db, "AddTeamMember", &user, `
+		INSERT INTO users (user_id, username, team_name, is_active, review_weight, max_open_reviews, updated_by)
+		SELECT $1, $2, team_name, COALESCE($4::boolean, true), COALESCE($5::double precision, 1), $6::integer,
+			NULLIF($7, '')
+		FROM teams WHERE team_name = $3
+		ON CONFLICT (user_id)
+		DO UPDATE SET username = $2, team_name = $3,
+			is_active = COALESCE($4::boolean, users.is_active),
+			review_weight = COALESCE($5::double precision, users.review_weight),
+			max_open_reviews = COALESCE($6::integer, users.max_open_reviews),
+			removed_at = NULL, updated_at = CURRENT_TIMESTAMP, updated_by = NULLIF($7, '')
+		RETURNING user_id, username, team_name, is_active, review_weight, max_open_reviews, out_of_office_until`,
+		member.UserID, member.Username, member.TeamName, member.IsActive, member.ReviewWeight, member.MaxOpenReviews,
+		auth.ActorFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("team not found")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *Repository) GetUserByID(ctx context.Context, userID string) (models.User, error) {
 	var user models.User
-	err := r.db.GetContext(ctx, &user,
-		"SELECT user_id, username, team_name, is_active FROM users WHERE user_id = $1", userID)
+	err := r.getContext(ctx, r.db, "GetUserByID", &user,
+		"SELECT user_id, username, team_name, is_active, review_weight, max_open_reviews, out_of_office_until FROM users WHERE user_id = $1", userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user, fmt.Errorf("user not found")
@@ -74,7 +113,7 @@ func (r *Repository) GetUserByID(ctx context.Context, userID string) (models.Use
 
 func (r *Repository) GetTeam(ctx context.Context, teamName string) (*models.Team, error) {
 	var team models.Team
-	err := r.db.GetContext(ctx, &team,
+	err := r.getContext(ctx, r.db, "GetTeam", &team,
 		"SELECT team_name FROM teams WHERE team_name = $1", teamName)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -84,8 +123,8 @@ func (r *Repository) GetTeam(ctx context.Context, teamName string) (*models.Team
 	}
 
 	var members []models.User
-	err = r.db.SelectContext(ctx, &members,
-		"SELECT user_id, username, team_name, is_active FROM users WHERE team_name = $1", teamName)
+	err = r.selectContext(ctx, r.db, "GetTeam", &members,
+		"SELECT user_id, username, team_name, is_active, review_weight, max_open_reviews, out_of_office_until FROM users WHERE team_name = $1 AND removed_at IS NULL", teamName)
 	if err != nil {
 		return nil, err
 	}
@@ -94,13 +133,152 @@ func (r *Repository) GetTeam(ctx context.Context, teamName string) (*models.Team
 	return &team, nil
 }
 
+// GetTeamPolicy returns the team's policy, or nil if the team has none
+func (r *Repository) GetTeamPolicy(ctx context.Context, teamName string) (*models.TeamPolicy, error) {
+	var policy models.TeamPolicy
+	err := r.getContext(ctx, r.db, "GetTeamPolicy", &policy,
+		"SELECT team_name, strategy, reviewer_count, affinity_window, affinity_penalty FROM team_policies WHERE team_name = $1", teamName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// GetTeamPolicies returns the policies of all teams that have one
+func (r *Repository) GetTeamPolicies(ctx context.Context) ([]models.TeamPolicy, error) {
+	var policies []models.TeamPolicy
+	err := r.selectContext(ctx, r.db, "GetTeamPolicies", &policies,
+		"SELECT team_name, strategy, reviewer_count, affinity_window, affinity_penalty FROM team_policies")
+	return policies, err
+}
+
+func (r *Repository) UpsertTeamPolicy(ctx context.Context, policy *models.TeamPolicy) error {
+	res, err := r.execContext(ctx, r.db, "UpsertTeamPolicy", `
+		INSERT INTO team_policies (team_name, strategy, reviewer_count, affinity_window, affinity_penalty, updated_by)
+		SELECT team_name, $2, $3, $4, $5, NULLIF($6, '') FROM teams WHERE team_name = $1
+		ON CONFLICT (team_name)
+		DO UPDATE SET strategy = $2, reviewer_count = $3, affinity_window = $4, affinity_penalty = $5,
+			updated_at = CURRENT_TIMESTAMP, updated_by = NULLIF($6, '')`,
+		policy.TeamName, policy.Strategy, policy.ReviewerCount, policy.AffinityWindow, policy.AffinityPenalty,
+		auth.ActorFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("team not found")
+	}
+	return nil
+}
+
+// GetRotationCursor returns the last user the team's rotation picked,
+// or an empty string if the rotation hasn't started
+func (r *Repository) GetRotationCursor(ctx context.Context, teamName string) (string, error) {
+	var lastUserID string
+	err := r.getContext(ctx, r.db, "GetRotationCursor", &lastUserID,
+		"SELECT last_user_id FROM team_rotation_cursors WHERE team_name = $1", teamName)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	return lastUserID, nil
+}
+
+// Rotation assigns reviewers by round-robin as part of a write. The write
+// locks the team's rotation cursor and reads the team's members in its own
+// transaction; Pick chooses reviewers from those members, and the cursor
+// moves to the last one picked only if the write commits. Concurrent writes
+// are serialized on the cursor, so each one sees the cursor left by the
+// previous.
+type Rotation struct {
+	TeamName string
+	Pick     func(lastUserID string, members []models.TeamMember) ([]string, error)
+}
+
+// rotate runs rotation inside tx and returns the reviewers it picked
+func (r *Repository) rotate(ctx context.Context, tx *sqlx.Tx, op string, rotation *Rotation) ([]string, error) {
+	_, err := r.execContext(ctx, tx, op, `
+		INSERT INTO team_rotation_cursors (team_name) VALUES ($1)
+		ON CONFLICT (team_name) DO NOTHING`, rotation.TeamName)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastUserID string
+	err = r.getContext(ctx, tx, op, &lastUserID,
+		"SELECT last_user_id FROM team_rotation_cursors WHERE team_name = $1 FOR UPDATE", rotation.TeamName)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []models.TeamMember
+	if err := r.selectContext(ctx, tx, op, &members, teamMembersQuery, rotation.TeamName); err != nil {
+		return nil, err
+	}
+
+	picked, err := rotation.Pick(lastUserID, members)
+	if err != nil {
+		return nil, err
+	}
+	if len(picked) == 0 {
+		return picked, nil
+	}
+
+	_, err = r.execContext(ctx, tx, op, `
+		UPDATE team_rotation_cursors SET last_user_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE team_name = $2`, picked[len(picked)-1], rotation.TeamName)
+	if err != nil {
+		return nil, err
+	}
+	return picked, nil
+}
+
 func (r *Repository) UpdateUserActivity(ctx context.Context, userID string, isActive bool) (*models.User, error) {
 	var user models.User
-	err := r.db.GetContext(ctx, &user, `
-		UPDATE users SET is_active = $1 
+	err := r.getContext(ctx, r.db, "UpdateUserActivity", &user, `
+		UPDATE users SET is_active = $1, updated_at = CURRENT_TIMESTAMP, updated_by = NULLIF($3, '')
+		WHERE user_id = $2 
+		RETURNING user_id, username, team_name, is_active, review_weight, max_open_reviews, out_of_office_until`,
+		isActive, userID, auth.ActorFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RemoveTeamMember takes the user out of their team and deactivates them.
+// The row stays for the PRs they wrote and reviewed; removing a removed
+// user keeps the original removal time.
+func (r *Repository) RemoveTeamMember(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	err := r.getContext(ctx, r.db, "RemoveTeamMember", &user, `
+		UPDATE users SET removed_at = COALESCE(removed_at, CURRENT_TIMESTAMP), is_active = false,
+			updated_at = CURRENT_TIMESTAMP, updated_by = NULLIF($2, '')
+		WHERE user_id = $1
+		RETURNING user_id, username, team_name, is_active, review_weight, max_open_reviews, out_of_office_until, removed_at`,
+		userID, auth.ActorFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *Repository) SetUserOutOfOffice(ctx context.Context, userID string, until *time.Time) (*models.User, error) {
+	var user models.User
+	err := r.getContext(ctx, r.db, "SetUserOutOfOffice", &user, `
+		UPDATE users SET out_of_office_until = $1, updated_at = CURRENT_TIMESTAMP, updated_by = NULLIF($3, '')
 		WHERE user_id = $2 
-		RETURNING user_id, username, team_name, is_active`,
-		isActive, userID)
+		RETURNING user_id, username, team_name, is_active, review_weight, max_open_reviews, out_of_office_until`,
+		until, userID, auth.ActorFromContext(ctx))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("user not found")
@@ -110,30 +288,69 @@ func (r *Repository) UpdateUserActivity(ctx context.Context, userID string, isAc
 	return &user, nil
 }
 
-func (r *Repository) CreatePullRequest(ctx context.Context, pr *models.PullRequest) error {
+// CreatePullRequest stores a new PR with its assignment history. With a
+// rotation, the reviewers are picked by it inside the same transaction and
+// stored in pr.AssignedReviewers.
+func (r *Repository) CreatePullRequest(ctx context.Context, pr *models.PullRequest, rotation *Rotation) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if rotation != nil {
+		pr.AssignedReviewers, err = r.rotate(ctx, tx, "CreatePullRequest", rotation)
+		if err != nil {
+			return err
+		}
+	}
+
 	reviewersJSON, err := json.Marshal(pr.AssignedReviewers)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.db.ExecContext(ctx, `
+	_, err = r.execContext(ctx, tx, "CreatePullRequest", `
 		INSERT INTO pull_requests 
-		(pull_request_id, pull_request_name, author_id, status, assigned_reviewers, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, reviewersJSON, pr.CreatedAt)
+		(pull_request_id, pull_request_name, author_id, status, assigned_reviewers, requested_reviewers, created_at,
+		 assignment_strategy, assignment_seed, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))`,
+		pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, reviewersJSON, pr.RequestedReviewers, pr.CreatedAt,
+		pr.AssignmentStrategy, pr.AssignmentSeed, auth.ActorFromContext(ctx))
 
-	if err != nil {
+	if isUniqueViolation(err, "pull_requests_pkey") {
 		return fmt.Errorf("PR id already exists")
 	}
-	return nil
+	if err != nil {
+		return fmt.Errorf("insert pull request: %w", err)
+	}
+
+	// Record each assignment in history
+	for _, reviewerID := range pr.AssignedReviewers {
+		_, err = r.execContext(ctx, tx, "CreatePullRequest", `
+			INSERT INTO reviewer_assignments (pull_request_id, reviewer_id, strategy, seed, assigned_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			pr.PullRequestID, reviewerID, pr.AssignmentStrategy, pr.AssignmentSeed, pr.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (r *Repository) GetPullRequest(ctx context.Context, prID string) (*models.PullRequest, error) {
-	var pr models.PullRequest
-	var reviewersJSON string
+	var row struct {
+		models.PullRequest
+		ReviewersJSON []byte `db:"assigned_reviewers"`
+	}
 
-	err := r.db.GetContext(ctx, &pr, `
-		SELECT pull_request_id, pull_request_name, author_id, status, assigned_reviewers, created_at, merged_at
+	err := r.getContext(ctx, r.db, "GetPullRequest", &row, `
+		SELECT pull_request_id, pull_request_name, author_id, status, assigned_reviewers, requested_reviewers,
+		       created_at, merged_at, assignment_strategy, assignment_seed, version
 		FROM pull_requests WHERE pull_request_id = $1`, prID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -142,7 +359,8 @@ func (r *Repository) GetPullRequest(ctx context.Context, prID string) (*models.P
 		return nil, err
 	}
 
-	err = json.Unmarshal([]byte(reviewersJSON), &pr.AssignedReviewers)
+	pr := row.PullRequest
+	err = json.Unmarshal(row.ReviewersJSON, &pr.AssignedReviewers)
 	if err != nil {
 		return nil, err
 	}
@@ -150,39 +368,220 @@ func (r *Repository) GetPullRequest(ctx context.Context, prID string) (*models.P
 	return &pr, nil
 }
 
+// UpdatePullRequest stores pr if the stored PR is still at pr.Version,
+// and increments the version. Otherwise it fails with "PR version conflict".
+// The caller is recorded as merged_by when the PR becomes merged.
 func (r *Repository) UpdatePullRequest(ctx context.Context, pr *models.PullRequest) error {
 	reviewersJSON, err := json.Marshal(pr.AssignedReviewers)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.db.ExecContext(ctx, `
+	res, err := r.execContext(ctx, r.db, "UpdatePullRequest", `
 		UPDATE pull_requests 
-		SET status = $1, assigned_reviewers = $2, merged_at = $3
-		WHERE pull_request_id = $4`,
-		pr.Status, reviewersJSON, pr.MergedAt, pr.PullRequestID)
-	return err
+		SET status = $1, assigned_reviewers = $2, merged_at = $3, version = version + 1, updated_at = CURRENT_TIMESTAMP,
+		    merged_by = CASE WHEN $1 = 'MERGED' THEN COALESCE(merged_by, NULLIF($6, '')) END
+		WHERE pull_request_id = $4 AND version = $5`,
+		pr.Status, reviewersJSON, pr.MergedAt, pr.PullRequestID, pr.Version, auth.ActorFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if err := checkVersionUpdated(res); err != nil {
+		return err
+	}
+	pr.Version++
+	return nil
 }
 
-func (r *Repository) GetActiveTeamMembers(ctx context.Context, teamName string, excludeUserID string) ([]models.User, error) {
-	var users []models.User
+func checkVersionUpdated(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("PR version conflict")
+	}
+	return nil
+}
+
+// ReplaceReviewer swaps replacement.OldUserID for replacement.NewUserID in
+// the PR's reviewer list and assignment history. With a rotation, the new
+// reviewer is picked by it inside the same transaction and stored in
+// replacement.NewUserID. Like UpdatePullRequest it checks and increments the
+// PR's version.
+func (r *Repository) ReplaceReviewer(ctx context.Context, pr *models.PullRequest, replacement *models.Replacement, rotation *Rotation) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if rotation != nil {
+		picked, err := r.rotate(ctx, tx, "ReplaceReviewer", rotation)
+		if err != nil {
+			return err
+		}
+		if len(picked) == 0 {
+			return fmt.Errorf("no active replacement candidate in team")
+		}
+		replacement.NewUserID = picked[0]
+	}
+
+	if err := r.checkDeclineQuota(ctx, tx, replacement.OldUserID, replacement.Unassignment); err != nil {
+		return err
+	}
+
+	reviewers := make([]string, len(pr.AssignedReviewers))
+	for i, reviewer := range pr.AssignedReviewers {
+		if reviewer == replacement.OldUserID {
+			reviewer = replacement.NewUserID
+		}
+		reviewers[i] = reviewer
+	}
+	reviewersJSON, err := json.Marshal(reviewers)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.execContext(ctx, tx, "ReplaceReviewer", `
+		UPDATE pull_requests 
+		SET assigned_reviewers = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE pull_request_id = $2 AND version = $3`,
+		reviewersJSON, pr.PullRequestID, pr.Version)
+	if err != nil {
+		return err
+	}
+	if err := checkVersionUpdated(res); err != nil {
+		return err
+	}
+
+	_, err = r.execContext(ctx, tx, "ReplaceReviewer", `
+		UPDATE reviewer_assignments 
+		SET unassigned_at = CURRENT_TIMESTAMP, replaced_by = $1,
+		    unassigned_by = NULLIF($4, ''), unassign_reason = NULLIF($5, ''), declined = $6
+		WHERE pull_request_id = $2 AND reviewer_id = $3 AND unassigned_at IS NULL`,
+		replacement.NewUserID, pr.PullRequestID, replacement.OldUserID,
+		replacement.Unassignment.Actor, replacement.Unassignment.Reason, replacement.Unassignment.Declined)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.execContext(ctx, tx, "ReplaceReviewer", `
+		INSERT INTO reviewer_assignments (pull_request_id, reviewer_id, strategy, seed)
+		VALUES ($1, $2, $3, $4)`,
+		pr.PullRequestID, replacement.NewUserID, replacement.Strategy, replacement.Seed)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	pr.AssignedReviewers = reviewers
+	pr.Version++
+	return nil
+}
+
+// checkDeclineQuota fails with "decline quota exceeded" if storing the
+// unassignment as a decline would exceed its DeclineLimit. The reviewer's
+// row stays locked until tx ends, so concurrent declines are counted one
+// after another.
+func (r *Repository) checkDeclineQuota(ctx context.Context, tx *sqlx.Tx, reviewerID string, unassignment models.Unassignment) error {
+	if !unassignment.Declined || unassignment.DeclineLimit == 0 {
+		return nil
+	}
+
+	var locked string
+	err := r.getContext(ctx, tx, "ReplaceReviewer", &locked,
+		"SELECT user_id FROM users WHERE user_id = $1 FOR UPDATE", reviewerID)
+	if err != nil {
+		return err
+	}
+
+	var declines int
+	err = r.getContext(ctx, tx, "ReplaceReviewer", &declines, `
+		SELECT COUNT(*) FROM reviewer_assignments
+		WHERE reviewer_id = $1 AND declined AND unassigned_at >= $2`,
+		reviewerID, unassignment.DeclineSince)
+	if err != nil {
+		return err
+	}
+	if declines >= unassignment.DeclineLimit {
+		return fmt.Errorf("decline quota exceeded")
+	}
+	return nil
+}
+
+// teamMembersQuery selects every member of a team with their open review
+// count, ordered by user_id so seeded selection is reproducible
+const teamMembersQuery = `
+	SELECT u.user_id, u.username, u.team_name, u.is_active, u.review_weight, u.max_open_reviews,
+	       u.out_of_office_until,
+	       (SELECT COUNT(*) FROM pull_requests pr
+	        WHERE pr.status = 'OPEN' AND pr.assigned_reviewers @> jsonb_build_array(u.user_id)) AS open_reviews
+	FROM users u
+	WHERE u.team_name = $1 AND u.removed_at IS NULL
+	ORDER BY u.user_id`
+
+// GetTeamMembers returns every member of the team with their open review count
+func (r *Repository) GetTeamMembers(ctx context.Context, teamName string) ([]models.TeamMember, error) {
+	var members []models.TeamMember
+	err := r.selectContext(ctx, r.db, "GetTeamMembers", &members, teamMembersQuery, teamName)
+	return members, err
+}
+
+func (r *Repository) CreateReview(ctx context.Context, review *models.Review) error {
+	return r.getContext(ctx, r.db, "CreateReview", review, `
+		INSERT INTO reviews (pull_request_id, reviewer_id, state, submitted_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, pull_request_id, reviewer_id, state, submitted_at`,
+		review.PullRequestID, review.ReviewerID, review.State, review.SubmittedAt)
+}
+
+// GetRecentReviewerCounts counts how many of the author's last limit PRs
+// each reviewer is assigned to
+func (r *Repository) GetRecentReviewerCounts(ctx context.Context, authorID string, limit int) (map[string]int, error) {
+	var rows []struct {
+		ReviewerID string `db:"reviewer_id"`
+		Count      int    `db:"count"`
+	}
 	query := `
-		SELECT user_id, username, team_name, is_active 
-		FROM users 
-		WHERE team_name = $1 AND is_active = true AND user_id != $2`
+		SELECT reviewer_id, COUNT(*) AS count
+		FROM (
+			SELECT assigned_reviewers FROM pull_requests
+			WHERE author_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		) recent, jsonb_array_elements_text(recent.assigned_reviewers) AS reviewer_id
+		GROUP BY reviewer_id`
 
-	err := r.db.SelectContext(ctx, &users, query, teamName, excludeUserID)
-	return users, err
+	if err := r.selectContext(ctx, r.db, "GetRecentReviewerCounts", &rows, query, authorID, limit); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.ReviewerID] = row.Count
+	}
+	return counts, nil
 }
 
-func (r *Repository) GetUserReviewPullRequests(ctx context.Context, userID string) ([]models.PullRequestShort, error) {
-	var prs []models.PullRequestShort
+// GetUserReviewPullRequests returns the PRs the user is assigned to, in
+// pull_request_id order
+func (r *Repository) GetUserReviewPullRequests(ctx context.Context, userID string, page models.PageRequest) ([]models.PullRequestShort, error) {
+	prs := []models.PullRequestShort{}
 
 	query := `
-		SELECT DISTINCT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status
 		FROM pull_requests pr
-		WHERE $1 = ANY(pr.assigned_reviewers)`
+		WHERE pr.assigned_reviewers @> jsonb_build_array($1::text)
+		  AND ($2 = '' OR pr.pull_request_id > $2)
+		ORDER BY pr.pull_request_id
+		LIMIT NULLIF($3, 0)`
 
-	err := r.db.SelectContext(ctx, &prs, query, userID)
+	err := r.selectContext(ctx, r.db, "GetUserReviewPullRequests", &prs, query, userID, page.Cursor, page.Limit)
 	return prs, err
 }
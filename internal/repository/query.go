@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/metrics"
+	"pr-reviewer-service/internal/tracing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Every statement goes through these helpers so it is measured, traced and
+// logged the same way whether it runs on r.db or inside a transaction. op
+// names the repository method the statement belongs to.
+
+func (r *Repository) getContext(ctx context.Context, q sqlx.QueryerContext, op string, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	ctx, finish := startQuery(ctx, op, query)
+	err := sqlx.GetContext(ctx, q, dest, query, args...)
+	finish(err)
+	return err
+}
+
+func (r *Repository) selectContext(ctx context.Context, q sqlx.QueryerContext, op string, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	ctx, finish := startQuery(ctx, op, query)
+	err := sqlx.SelectContext(ctx, q, dest, query, args...)
+	finish(err)
+	return err
+}
+
+func (r *Repository) execContext(ctx context.Context, e sqlx.ExecerContext, op string, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	ctx, finish := startQuery(ctx, op, query)
+	res, err := e.ExecContext(ctx, query, args...)
+	finish(err)
+	return res, err
+}
+
+// withTimeout applies the query timeout to ctx. A deadline ctx already has
+// is kept if it is earlier.
+func (r *Repository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.queryTimeout)
+}
+
+// startQuery opens a span for the statement. The returned function records
+// the statement's outcome in metrics, logs and the span.
+func startQuery(ctx context.Context, op string, query string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer.Start(ctx, "db "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(op),
+			semconv.DBStatement(query),
+		),
+	)
+
+	return ctx, func(err error) {
+		// A missing row is an expected outcome, not a failed query
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		metrics.ObserveQuery(op, start, err)
+		tracing.End(span, err)
+
+		logger := logging.FromContext(ctx)
+		if err != nil {
+			logger.Error("query failed", "op", op, "duration", time.Since(start), "error", err)
+			return
+		}
+		logger.Debug("query", "op", op, "duration", time.Since(start))
+	}
+}
+
+// uniqueViolation is the SQLSTATE of a unique or primary key conflict
+const uniqueViolation = "23505"
+
+// isUniqueViolation reports whether err is a unique violation of constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == constraint
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"primary key conflict", &pgconn.PgError{Code: "23505", ConstraintName: "pull_requests_pkey"}, true},
+		{"wrapped conflict", fmt.Errorf("exec: %w", &pgconn.PgError{Code: "23505", ConstraintName: "pull_requests_pkey"}), true},
+		{"other constraint", &pgconn.PgError{Code: "23505", ConstraintName: "api_tokens_token_hash_key"}, false},
+		{"foreign key violation", &pgconn.PgError{Code: "23503", ConstraintName: "pull_requests_pkey"}, false},
+		{"query timeout", context.DeadlineExceeded, false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isUniqueViolation(tt.err, "pull_requests_pkey"); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
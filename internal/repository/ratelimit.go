@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// refilledTokens is the bucket's token count refilled up to now; rate is
+// $2 tokens per second and burst $3
+const refilledTokens = "LEAST($3::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM now() - b.updated_at)::float8, 0) * $2::float8)"
+
+// TakeRateLimitToken refills the bucket and takes a token from it in one
+// statement, so concurrent requests from every replica are serialized on
+// the bucket's row
+func (r *Repository) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	var result struct {
+		Allowed bool    `db:"allowed"`
+		Tokens  float64 `db:"tokens"`
+	}
+	err := r.getContext(ctx, r.db, "TakeRateLimitToken", &result, `
+		INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, allowed, updated_at)
+		VALUES ($1, $3::float8 - 1, true, now())
+		ON CONFLICT (bucket_key) DO UPDATE SET
+		    tokens = CASE WHEN `+refilledTokens+` >= 1 THEN `+refilledTokens+` - 1 ELSE `+refilledTokens+` END,
+		    allowed = `+refilledTokens+` >= 1,
+		    updated_at = now()
+		RETURNING allowed, tokens`,
+		key, rate, burst)
+	return result.Allowed, result.Tokens, err
+}
+
+// DeleteIdleRateLimitBuckets deletes buckets unused for idle
+func (r *Repository) DeleteIdleRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error) {
+	res, err := r.execContext(ctx, r.db, "DeleteIdleRateLimitBuckets",
+		"DELETE FROM rate_limit_buckets WHERE updated_at < now() - make_interval(secs => $1)", idle.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SchemaVersion is the number of the newest file in migrations/. Bump it
+// together with schemaStatements whenever a migration is added.
+const SchemaVersion = 20
+
+// schemaStatements bring any older schema up to date; each one is idempotent
+var schemaStatements = []string{
+	`CREATE TABLE IF NOT EXISTS teams (
+            team_name VARCHAR(255) PRIMARY KEY,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+
+	`CREATE TABLE IF NOT EXISTS users (
+            user_id VARCHAR(255) PRIMARY KEY,
+            username VARCHAR(255) NOT NULL,
+            team_name VARCHAR(255) NOT NULL REFERENCES teams(team_name) ON DELETE CASCADE,
+            is_active BOOLEAN DEFAULT true,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+
+	`CREATE TABLE IF NOT EXISTS pull_requests (
+            pull_request_id VARCHAR(255) PRIMARY KEY,
+            pull_request_name VARCHAR(255) NOT NULL,
+            author_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
+            status VARCHAR(50) NOT NULL DEFAULT 'OPEN',
+            assigned_reviewers JSONB NOT NULL DEFAULT '[]',
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            merged_at TIMESTAMP NULL,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+
+	`CREATE INDEX IF NOT EXISTS idx_users_team_active ON users(team_name, is_active)`,
+	`CREATE INDEX IF NOT EXISTS idx_pr_author ON pull_requests(author_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_pr_status ON pull_requests(status)`,
+
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS review_weight DOUBLE PRECISION NOT NULL DEFAULT 1`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS max_open_reviews INTEGER NULL`,
+
+	`ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS assignment_strategy VARCHAR(50) NOT NULL DEFAULT ''`,
+	`ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS assignment_seed BIGINT NOT NULL DEFAULT 0`,
+
+	`CREATE TABLE IF NOT EXISTS reviewer_assignments (
+            id BIGSERIAL PRIMARY KEY,
+            pull_request_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+            reviewer_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
+            strategy VARCHAR(50) NOT NULL,
+            seed BIGINT NOT NULL,
+            assigned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            unassigned_at TIMESTAMP NULL,
+            replaced_by VARCHAR(255) NULL REFERENCES users(user_id)
+        )`,
+
+	`CREATE INDEX IF NOT EXISTS idx_assignments_pr ON reviewer_assignments(pull_request_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_assignments_reviewer ON reviewer_assignments(reviewer_id)`,
+
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS out_of_office_until TIMESTAMP NULL`,
+
+	`CREATE TABLE IF NOT EXISTS team_policies (
+            team_name VARCHAR(255) PRIMARY KEY REFERENCES teams(team_name) ON DELETE CASCADE,
+            strategy VARCHAR(50) NOT NULL,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+
+	`CREATE TABLE IF NOT EXISTS team_rotation_cursors (
+            team_name VARCHAR(255) PRIMARY KEY REFERENCES teams(team_name) ON DELETE CASCADE,
+            last_user_id VARCHAR(255) NOT NULL DEFAULT '',
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+
+	`ALTER TABLE team_policies ADD COLUMN IF NOT EXISTS affinity_window INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE team_policies ADD COLUMN IF NOT EXISTS affinity_penalty DOUBLE PRECISION NOT NULL DEFAULT 0.5`,
+
+	`CREATE INDEX IF NOT EXISTS idx_assignments_assigned_at ON reviewer_assignments(assigned_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_pr_created_at ON pull_requests(created_at)`,
+
+	`CREATE TABLE IF NOT EXISTS reviews (
+            id BIGSERIAL PRIMARY KEY,
+            pull_request_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+            reviewer_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
+            state VARCHAR(50) NOT NULL,
+            submitted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`,
+
+	`CREATE INDEX IF NOT EXISTS idx_reviews_pr ON reviews(pull_request_id, submitted_at)`,
+
+	`ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS requested_reviewers INTEGER NOT NULL DEFAULT 2`,
+
+	`CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+
+	`ALTER TABLE team_policies ADD COLUMN IF NOT EXISTS reviewer_count INTEGER NOT NULL DEFAULT 2`,
+
+	`CREATE OR REPLACE FUNCTION notify_team_policies_changed() RETURNS trigger AS $$
+        BEGIN
+            IF TG_OP = 'DELETE' THEN
+                PERFORM pg_notify('` + TeamPoliciesChannel + `', OLD.team_name);
+            ELSE
+                PERFORM pg_notify('` + TeamPoliciesChannel + `', NEW.team_name);
+            END IF;
+            RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql`,
+
+	`DROP TRIGGER IF EXISTS team_policies_changed ON team_policies`,
+
+	`CREATE TRIGGER team_policies_changed
+            AFTER INSERT OR UPDATE OR DELETE ON team_policies
+            FOR EACH ROW EXECUTE FUNCTION notify_team_policies_changed()`,
+
+	`CREATE TABLE IF NOT EXISTS api_tokens (
+            token_id VARCHAR(64) PRIMARY KEY,
+            name VARCHAR(255) NOT NULL,
+            token_hash CHAR(64) NOT NULL UNIQUE,
+            role VARCHAR(20) NOT NULL,
+            team_name VARCHAR(255) NULL REFERENCES teams(team_name) ON DELETE CASCADE,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            revoked_at TIMESTAMP NULL
+        )`,
+
+	`ALTER TABLE reviewer_assignments ADD COLUMN IF NOT EXISTS unassigned_by VARCHAR(255) NULL`,
+	`ALTER TABLE reviewer_assignments ADD COLUMN IF NOT EXISTS unassign_reason TEXT NULL`,
+	`ALTER TABLE reviewer_assignments ADD COLUMN IF NOT EXISTS declined BOOLEAN NOT NULL DEFAULT false`,
+	`CREATE INDEX IF NOT EXISTS idx_assignments_declines ON reviewer_assignments(reviewer_id, unassigned_at) WHERE declined`,
+
+	`CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+            bucket_key TEXT PRIMARY KEY,
+            tokens DOUBLE PRECISION NOT NULL,
+            allowed BOOLEAN NOT NULL,
+            updated_at TIMESTAMPTZ NOT NULL
+        )`,
+
+	`CREATE TABLE IF NOT EXISTS idempotency_keys (
+            scope VARCHAR(255) NOT NULL,
+            idempotency_key VARCHAR(255) NOT NULL,
+            request_hash CHAR(64) NOT NULL,
+            status_code INTEGER NULL,
+            content_type VARCHAR(255) NULL,
+            response_body BYTEA NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            expires_at TIMESTAMPTZ NOT NULL,
+            PRIMARY KEY (scope, idempotency_key)
+        )`,
+
+	`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at)`,
+
+	`ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1`,
+
+	`ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS user_id VARCHAR(255) NULL REFERENCES users(user_id) ON DELETE CASCADE`,
+
+	`ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS etag VARCHAR(255) NULL`,
+	`ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS retry_after VARCHAR(32) NULL`,
+
+	`ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS created_by VARCHAR(255) NULL`,
+	`ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS merged_by VARCHAR(255) NULL`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_by VARCHAR(255) NULL`,
+	`ALTER TABLE team_policies ADD COLUMN IF NOT EXISTS updated_by VARCHAR(255) NULL`,
+
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP NULL`,
+}
+
+// dataMigrations copy existing rows into a newer layout. They scan whole
+// tables, so each runs only on a database that hasn't recorded its version.
+var dataMigrations = []struct {
+	version   int
+	statement string
+}{
+	{7, `INSERT INTO reviewer_assignments (pull_request_id, reviewer_id, strategy, seed, assigned_at)
+        SELECT pr.pull_request_id, reviewer.id, pr.assignment_strategy, pr.assignment_seed, pr.created_at
+        FROM pull_requests pr, jsonb_array_elements_text(pr.assigned_reviewers) AS reviewer(id)
+        WHERE NOT EXISTS (SELECT 1 FROM reviewer_assignments a WHERE a.pull_request_id = pr.pull_request_id)`},
+}
+
+// InitSchema applies schemaStatements, runs the dataMigrations newer than the
+// recorded version and records SchemaVersion as applied
+func InitSchema(ctx context.Context, db *sqlx.DB) error {
+	for _, statement := range schemaStatements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	var applied int
+	if err := db.GetContext(ctx, &applied, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	for _, migration := range dataMigrations {
+		if migration.version <= applied {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, migration.statement); err != nil {
+			return fmt.Errorf("data migration %d: %w", migration.version, err)
+		}
+	}
+
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT (version) DO NOTHING", SchemaVersion)
+	if err != nil {
+		return fmt.Errorf("record schema version: %w", err)
+	}
+	return nil
+}
+
+// GetSchemaVersion returns the newest schema version applied to the database
+func (r *Repository) GetSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := r.getContext(ctx, r.db, "GetSchemaVersion", &version,
+		"SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	return version, err
+}
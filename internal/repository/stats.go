@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"pr-reviewer-service/internal/models"
+	"time"
+)
+
+// GetReviewerStats aggregates assignment history per user. Assignments are
+// attributed to the period they were made in; open and merged reviews only
+// count assignments that weren't later reassigned away.
+func (r *Repository) GetReviewerStats(ctx context.Context, filter models.StatsFilter) ([]models.ReviewerStats, error) {
+	stats := []models.ReviewerStats{}
+	query := `
+		SELECT u.user_id, u.username, u.team_name,
+		       COUNT(a.id) AS assignments,
+		       COUNT(a.id) FILTER (WHERE a.unassigned_at IS NULL AND pr.status = 'OPEN') AS open_reviews,
+		       COUNT(a.id) FILTER (WHERE a.unassigned_at IS NULL AND pr.status = 'MERGED') AS merged_reviews,
+		       COUNT(a.id) FILTER (WHERE a.unassigned_at IS NOT NULL) AS reassigned_away
+		FROM users u
+		LEFT JOIN reviewer_assignments a ON a.reviewer_id = u.user_id
+		     AND ($2::timestamp IS NULL OR a.assigned_at >= $2)
+		     AND ($3::timestamp IS NULL OR a.assigned_at < $3)
+		LEFT JOIN pull_requests pr ON pr.pull_request_id = a.pull_request_id
+		WHERE ($1 = '' OR u.team_name = $1)
+		GROUP BY u.user_id, u.username, u.team_name
+		ORDER BY u.user_id`
+
+	err := r.selectContext(ctx, r.db, "GetReviewerStats", &stats, query, filter.TeamName, filter.From, filter.To)
+	return stats, err
+}
+
+// GetPullRequestStats counts PRs created in the period by status and computes
+// the median time to merge. The team filter applies to the author's team.
+func (r *Repository) GetPullRequestStats(ctx context.Context, filter models.StatsFilter) (*models.PullRequestStats, error) {
+	var rows []struct {
+		Status            string   `db:"status"`
+		Count             int      `db:"count"`
+		MedianTimeToMerge *float64 `db:"median_time_to_merge"`
+	}
+	query := `
+		SELECT COALESCE(pr.status, '') AS status,
+		       COUNT(*) AS count,
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM pr.merged_at - pr.created_at))
+		           FILTER (WHERE pr.merged_at IS NOT NULL) AS median_time_to_merge
+		FROM pull_requests pr
+		JOIN users u ON u.user_id = pr.author_id
+		WHERE ($1 = '' OR u.team_name = $1)
+		  AND ($2::timestamp IS NULL OR pr.created_at >= $2)
+		  AND ($3::timestamp IS NULL OR pr.created_at < $3)
+		GROUP BY GROUPING SETS ((pr.status), ())`
+
+	err := r.selectContext(ctx, r.db, "GetPullRequestStats", &rows, query, filter.TeamName, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.PullRequestStats{ByStatus: map[string]int{}}
+	for _, row := range rows {
+		// The empty grouping set is the total over all statuses
+		if row.Status == "" {
+			stats.Total = row.Count
+			stats.MedianTimeToMergeSeconds = row.MedianTimeToMerge
+			continue
+		}
+		stats.ByStatus[row.Status] = row.Count
+	}
+	return stats, nil
+}
+
+// cycleTimeRow is one row of the cycle time queries, flattened for scanning
+type cycleTimeRow struct {
+	GroupKey       string    `db:"group_key"`
+	Week           time.Time `db:"week"`
+	PullRequests   int       `db:"pull_requests"`
+	FirstReviewP50 *float64  `db:"first_review_p50"`
+	FirstReviewP90 *float64  `db:"first_review_p90"`
+	FirstReviewP99 *float64  `db:"first_review_p99"`
+	ApprovalP50    *float64  `db:"approval_p50"`
+	ApprovalP90    *float64  `db:"approval_p90"`
+	ApprovalP99    *float64  `db:"approval_p99"`
+	MergeP50       *float64  `db:"merge_p50"`
+	MergeP90       *float64  `db:"merge_p90"`
+	MergeP99       *float64  `db:"merge_p99"`
+}
+
+// cycleTimePercentiles aggregates the per-PR durations of the times CTE.
+// percentile_cont skips NULLs, so PRs that haven't reached a point yet
+// don't count towards that distribution.
+const cycleTimePercentiles = `
+		SELECT group_key, week, COUNT(*) AS pull_requests,
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY first_review) AS first_review_p50,
+		       percentile_cont(0.9) WITHIN GROUP (ORDER BY first_review) AS first_review_p90,
+		       percentile_cont(0.99) WITHIN GROUP (ORDER BY first_review) AS first_review_p99,
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY approval) AS approval_p50,
+		       percentile_cont(0.9) WITHIN GROUP (ORDER BY approval) AS approval_p90,
+		       percentile_cont(0.99) WITHIN GROUP (ORDER BY approval) AS approval_p99,
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY merged) AS merge_p50,
+		       percentile_cont(0.9) WITHIN GROUP (ORDER BY merged) AS merge_p90,
+		       percentile_cont(0.99) WITHIN GROUP (ORDER BY merged) AS merge_p99
+		FROM times
+		GROUP BY group_key, week
+		ORDER BY group_key, week`
+
+// GetCycleTimeStats computes weekly distributions of time from PR creation to
+// first review, approval and merge, per author team and per assigned reviewer.
+// PRs are bucketed by the week they were created in.
+func (r *Repository) GetCycleTimeStats(ctx context.Context, filter models.StatsFilter) (*models.CycleTimeStats, error) {
+	var teamRows []cycleTimeRow
+	teamQuery := `
+		WITH times AS (
+			SELECT u.team_name AS group_key,
+			       date_trunc('week', pr.created_at) AS week,
+			       EXTRACT(EPOCH FROM MIN(rv.submitted_at) - pr.created_at) AS first_review,
+			       EXTRACT(EPOCH FROM MIN(rv.submitted_at) FILTER (WHERE rv.state = 'APPROVED') - pr.created_at) AS approval,
+			       EXTRACT(EPOCH FROM pr.merged_at - pr.created_at) AS merged
+			FROM pull_requests pr
+			JOIN users u ON u.user_id = pr.author_id
+			LEFT JOIN reviews rv ON rv.pull_request_id = pr.pull_request_id
+			WHERE ($1 = '' OR u.team_name = $1)
+			  AND ($2::timestamp IS NULL OR pr.created_at >= $2)
+			  AND ($3::timestamp IS NULL OR pr.created_at < $3)
+			GROUP BY pr.pull_request_id, u.team_name, pr.created_at, pr.merged_at
+		)` + cycleTimePercentiles
+
+	err := r.selectContext(ctx, r.db, "GetCycleTimeStats", &teamRows, teamQuery, filter.TeamName, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// Per reviewer, first review and approval are the reviewer's own
+	var reviewerRows []cycleTimeRow
+	reviewerQuery := `
+		WITH times AS (
+			SELECT a.reviewer_id AS group_key,
+			       date_trunc('week', pr.created_at) AS week,
+			       EXTRACT(EPOCH FROM MIN(rv.submitted_at) - pr.created_at) AS first_review,
+			       EXTRACT(EPOCH FROM MIN(rv.submitted_at) FILTER (WHERE rv.state = 'APPROVED') - pr.created_at) AS approval,
+			       EXTRACT(EPOCH FROM pr.merged_at - pr.created_at) AS merged
+			FROM reviewer_assignments a
+			JOIN pull_requests pr ON pr.pull_request_id = a.pull_request_id
+			JOIN users u ON u.user_id = a.reviewer_id
+			LEFT JOIN reviews rv ON rv.pull_request_id = a.pull_request_id AND rv.reviewer_id = a.reviewer_id
+			WHERE ($1 = '' OR u.team_name = $1)
+			  AND ($2::timestamp IS NULL OR pr.created_at >= $2)
+			  AND ($3::timestamp IS NULL OR pr.created_at < $3)
+			GROUP BY a.reviewer_id, pr.pull_request_id, pr.created_at, pr.merged_at
+		)` + cycleTimePercentiles
+
+	err = r.selectContext(ctx, r.db, "GetCycleTimeStats", &reviewerRows, reviewerQuery, filter.TeamName, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.CycleTimeStats{
+		Teams:     make([]models.CycleTimeBucket, len(teamRows)),
+		Reviewers: make([]models.CycleTimeBucket, len(reviewerRows)),
+	}
+	for i, row := range teamRows {
+		stats.Teams[i] = row.bucket()
+		stats.Teams[i].TeamName = row.GroupKey
+	}
+	for i, row := range reviewerRows {
+		stats.Reviewers[i] = row.bucket()
+		stats.Reviewers[i].ReviewerID = row.GroupKey
+	}
+	return stats, nil
+}
+
+func (row cycleTimeRow) bucket() models.CycleTimeBucket {
+	return models.CycleTimeBucket{
+		Week:              row.Week,
+		PullRequests:      row.PullRequests,
+		TimeToFirstReview: models.Percentiles{P50: row.FirstReviewP50, P90: row.FirstReviewP90, P99: row.FirstReviewP99},
+		TimeToApproval:    models.Percentiles{P50: row.ApprovalP50, P90: row.ApprovalP90, P99: row.ApprovalP99},
+		TimeToMerge:       models.Percentiles{P50: row.MergeP50, P90: row.MergeP90, P99: row.MergeP99},
+	}
+}
+
+// GetDomainMetrics computes the values behind the domain gauges
+func (r *Repository) GetDomainMetrics(ctx context.Context) (*models.DomainMetrics, error) {
+	snapshot := &models.DomainMetrics{
+		OpenPullRequestsByTeam: map[string]int{},
+		OpenReviewsByUser:      map[string]int{},
+	}
+
+	var teamRows []struct {
+		TeamName string `db:"team_name"`
+		Count    int    `db:"count"`
+	}
+	err := r.selectContext(ctx, r.db, "GetDomainMetrics", &teamRows, `
+		SELECT u.team_name, COUNT(*) AS count
+		FROM pull_requests pr
+		JOIN users u ON u.user_id = pr.author_id
+		WHERE pr.status = 'OPEN'
+		GROUP BY u.team_name`)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range teamRows {
+		snapshot.OpenPullRequestsByTeam[row.TeamName] = row.Count
+	}
+
+	var userRows []struct {
+		UserID string `db:"user_id"`
+		Count  int    `db:"count"`
+	}
+	err = r.selectContext(ctx, r.db, "GetDomainMetrics", &userRows, `
+		SELECT reviewer.id AS user_id, COUNT(*) AS count
+		FROM pull_requests pr, jsonb_array_elements_text(pr.assigned_reviewers) AS reviewer(id)
+		WHERE pr.status = 'OPEN'
+		GROUP BY reviewer.id`)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range userRows {
+		snapshot.OpenReviewsByUser[row.UserID] = row.Count
+	}
+
+	err = r.getContext(ctx, r.db, "GetDomainMetrics", &snapshot.UnderstaffedPullRequests, `
+		SELECT COUNT(*) FROM pull_requests
+		WHERE status = 'OPEN' AND jsonb_array_length(assigned_reviewers) < requested_reviewers`)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pr-reviewer-service/internal/models"
+)
+
+const apiTokenColumns = "token_id, name, role, COALESCE(team_name, '') AS team_name, COALESCE(user_id, '') AS user_id, created_at, revoked_at"
+
+func (r *Repository) CreateAPIToken(ctx context.Context, token *models.APIToken, tokenHash string) error {
+	return r.getContext(ctx, r.db, "CreateAPIToken", &token.CreatedAt, `
+		INSERT INTO api_tokens (token_id, name, token_hash, role, team_name, user_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+		RETURNING created_at`,
+		token.TokenID, token.Name, tokenHash, token.Role, token.TeamName, token.UserID)
+}
+
+// GetAPITokenByHash returns the unrevoked token with the given hash,
+// or nil if there is none
+func (r *Repository) GetAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	var token models.APIToken
+	err := r.getContext(ctx, r.db, "GetAPITokenByHash", &token,
+		"SELECT "+apiTokenColumns+" FROM api_tokens WHERE token_hash = $1 AND revoked_at IS NULL", tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListAPITokens returns tokens in the order they were issued. Tokens are
+// revoked but never deleted, so the cursor token always exists.
+func (r *Repository) ListAPITokens(ctx context.Context, page models.PageRequest) ([]models.APIToken, error) {
+	tokens := []models.APIToken{}
+	err := r.selectContext(ctx, r.db, "ListAPITokens", &tokens, `
+		SELECT `+apiTokenColumns+` FROM api_tokens
+		WHERE $1 = '' OR (created_at, token_id) > (SELECT created_at, token_id FROM api_tokens WHERE token_id = $1)
+		ORDER BY created_at, token_id
+		LIMIT NULLIF($2, 0)`,
+		page.Cursor, page.Limit)
+	return tokens, err
+}
+
+// RevokeAPIToken marks the token revoked. Revoking a revoked token keeps
+// the original revocation time.
+func (r *Repository) RevokeAPIToken(ctx context.Context, tokenID string) (*models.APIToken, error) {
+	var token models.APIToken
+	err := r.getContext(ctx, r.db, "RevokeAPIToken", &token, `
+		UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)
+		WHERE token_id = $1
+		RETURNING `+apiTokenColumns, tokenID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("token not found")
+		}
+		return nil, err
+	}
+	return &token, nil
+}
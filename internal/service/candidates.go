@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"pr-reviewer-service/internal/models"
+	"time"
+)
+
+// candidatePool is the result of candidate discovery for one team
+type candidatePool struct {
+	Candidates []models.User
+	Excluded   []models.CandidateExclusion
+}
+
+func (p *candidatePool) candidateIDs() []string {
+	ids := make([]string, len(p.Candidates))
+	for i, candidate := range p.Candidates {
+		ids[i] = candidate.UserID
+	}
+	return ids
+}
+
+// discoverCandidates splits the team into reviewer candidates and excluded
+// members, recording why each excluded member can't review authorID's PR.
+func (s *Service) discoverCandidates(ctx context.Context, teamName string, authorID string) (*candidatePool, error) {
+	members, err := s.repo.GetTeamMembers(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pool := &candidatePool{
+		Candidates: []models.User{},
+		Excluded:   []models.CandidateExclusion{},
+	}
+	for _, member := range members {
+		reason := exclusionReason(member, authorID, now)
+		if reason != "" {
+			pool.Excluded = append(pool.Excluded, models.CandidateExclusion{UserID: member.UserID, Reason: reason})
+			continue
+		}
+		pool.Candidates = append(pool.Candidates, member.User)
+	}
+	return pool, nil
+}
+
+func exclusionReason(member models.TeamMember, authorID string, now time.Time) string {
+	switch {
+	case member.UserID == authorID:
+		return models.ExclusionAuthor
+	case !member.IsActive:
+		return models.ExclusionInactive
+	case member.OutOfOfficeUntil != nil && member.OutOfOfficeUntil.After(now):
+		return models.ExclusionOutOfOffice
+	case member.MaxOpenReviews != nil && member.OpenReviews >= *member.MaxOpenReviews:
+		return models.ExclusionAtCapacity
+	}
+	return ""
+}
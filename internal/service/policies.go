@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/worker"
+	"time"
+)
+
+// policyIdleTimeout is how long the policy watcher waits for a notification
+// before it reports a heartbeat anyway
+const policyIdleTimeout = 30 * time.Second
+
+// policySnapshot is an immutable view of the selection settings. It is
+// replaced as a whole, so a request that loads it once sees one consistent
+// set of settings however many reloads happen meanwhile.
+type policySnapshot struct {
+	defaultStrategy string
+	declineQuota    DeclineQuota
+	teams           map[string]models.TeamPolicy
+}
+
+// DeclineQuota limits how many assignments a reviewer may decline within
+// a rolling Period; a zero Limit means no limit
+type DeclineQuota struct {
+	Limit  int
+	Period time.Duration
+}
+
+// teamPolicy returns the team's policy, filling in service defaults
+// for teams that haven't set one
+func (s *Service) teamPolicy(teamName string) *models.TeamPolicy {
+	snapshot := s.policies.Load()
+	if policy, ok := snapshot.teams[teamName]; ok {
+		return &policy
+	}
+	return &models.TeamPolicy{
+		TeamName:        teamName,
+		Strategy:        snapshot.defaultStrategy,
+		ReviewerCount:   defaultReviewerCount,
+		AffinityPenalty: defaultAffinityPenalty,
+	}
+}
+
+// SetDefaultStrategy changes the strategy used by teams without a policy
+func (s *Service) SetDefaultStrategy(strategy string) error {
+	if !models.IsValidStrategy(strategy) {
+		return fmt.Errorf("unknown reviewer strategy %q", strategy)
+	}
+
+	s.updatePolicies(func(next *policySnapshot) {
+		next.defaultStrategy = strategy
+	})
+	return nil
+}
+
+// SetDeclineQuota changes how many assignments reviewers may decline
+func (s *Service) SetDeclineQuota(quota DeclineQuota) error {
+	if quota.Limit < 0 || (quota.Limit > 0 && quota.Period <= 0) {
+		return fmt.Errorf("invalid decline quota")
+	}
+
+	s.updatePolicies(func(next *policySnapshot) {
+		next.declineQuota = quota
+	})
+	return nil
+}
+
+// ReloadPolicies replaces the cached team policies with the ones in the
+// database. Reloads run one at a time, so the last one to finish also read
+// last.
+func (s *Service) ReloadPolicies(ctx context.Context) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	policies, err := s.repo.GetTeamPolicies(ctx)
+	if err != nil {
+		return err
+	}
+
+	teams := make(map[string]models.TeamPolicy, len(policies))
+	for _, policy := range policies {
+		teams[policy.TeamName] = policy
+	}
+
+	s.updatePolicies(func(next *policySnapshot) {
+		next.teams = teams
+	})
+	return nil
+}
+
+// updatePolicies replaces the snapshot with a copy changed by update,
+// retrying if another update got in first
+func (s *Service) updatePolicies(update func(next *policySnapshot)) {
+	for {
+		current := s.policies.Load()
+		next := *current
+		update(&next)
+		if s.policies.CompareAndSwap(current, &next) {
+			return
+		}
+	}
+}
+
+// WatchTeamPolicies reloads the cached team policies whenever team_policies
+// changes, until ctx is done. It runs as a background worker: lost
+// connections are retried with exponential backoff and every policy change
+// or idle timeout counts as a heartbeat.
+func (s *Service) WatchTeamPolicies(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	backoff := time.Second
+
+	for {
+		err := s.repo.ListenTeamPolicyChanges(ctx, policyIdleTimeout, func(notified bool) {
+			worker.Heartbeat(ctx)
+			backoff = time.Second
+			if !notified {
+				return
+			}
+			if err := s.ReloadPolicies(ctx); err != nil {
+				logger.Error("Failed to reload team policies", "error", err)
+				return
+			}
+			logger.Info("Team policies reloaded")
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Error("Team policy listener failed, retrying", "error", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > policyIdleTimeout {
+			backoff = policyIdleTimeout
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"pr-reviewer-service/internal/logging"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/repository"
+	"sort"
+	"time"
+)
+
+// seedFor returns the seed an assignment made with the given strategy should use.
+// keys identify the assignment (PR ID and, for reassignments, the replaced reviewer).
+func (s *Service) seedFor(strategy string, keys ...string) int64 {
+	if strategy == models.StrategyRoundRobin {
+		return 0
+	}
+	if strategy == models.StrategyPRSeeded {
+		h := fnv.New64a()
+		for _, key := range keys {
+			h.Write([]byte(key))
+			h.Write([]byte{0})
+		}
+		return int64(h.Sum64())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Int63()
+}
+
+// newSelectionRand returns the generator a selection with the given seed runs on.
+// Replaying a selection only needs the candidates and the seed.
+func newSelectionRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// applyAntiAffinity down-weights candidates who reviewed the author's recent
+// PRs so reviews spread across the team. Round-robin ignores weights, so the
+// policy only affects the random strategies; strategy is the one selection
+// runs with, which a preview may have overridden.
+func (s *Service) applyAntiAffinity(ctx context.Context, policy *models.TeamPolicy, strategy string, authorID string, candidates []models.User) ([]models.User, error) {
+	if policy.AffinityWindow <= 0 || strategy == models.StrategyRoundRobin {
+		return candidates, nil
+	}
+
+	recent, err := s.repo.GetRecentReviewerCounts(ctx, authorID, policy.AffinityWindow)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("applying anti-affinity",
+		"author_id", authorID,
+		"window", policy.AffinityWindow,
+		"penalty", policy.AffinityPenalty,
+		"recent_reviewers", recent,
+	)
+	return penalizeRecentReviewers(candidates, recent, policy.AffinityPenalty), nil
+}
+
+func penalizeRecentReviewers(candidates []models.User, recent map[string]int, penalty float64) []models.User {
+	adjusted := make([]models.User, len(candidates))
+	for i, candidate := range candidates {
+		if candidate.ReviewWeight <= 0 {
+			candidate.ReviewWeight = defaultReviewWeight
+		}
+		candidate.ReviewWeight = math.Max(candidate.ReviewWeight*math.Pow(penalty, float64(recent[candidate.UserID])), minPenalizedWeight)
+		adjusted[i] = candidate
+	}
+	return adjusted
+}
+
+// pickReviewers selects up to count reviewers from candidates. For
+// round-robin it only reports who the rotation would pick next; writes pick
+// through a rotation instead (see newRotation).
+func (s *Service) pickReviewers(ctx context.Context, teamName string, strategy string, seed int64, candidates []models.User, count int) ([]string, error) {
+	if strategy != models.StrategyRoundRobin {
+		reviewers := s.selectRandomReviewers(newSelectionRand(seed), candidates, count)
+		ids := make([]string, len(reviewers))
+		for i, reviewer := range reviewers {
+			ids[i] = reviewer.UserID
+		}
+		return ids, nil
+	}
+
+	candidateIDs := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		candidateIDs[i] = candidate.UserID
+	}
+
+	lastUserID, err := s.repo.GetRotationCursor(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	return nextInRotation(candidateIDs, lastUserID, count), nil
+}
+
+// newRotation returns the rotation a write uses to pick up to count
+// reviewers of teamName by round-robin. Candidates are the members eligible
+// accepts among those the write reads while it holds the cursor's lock, so
+// discovery and the pick see the same team.
+func newRotation(ctx context.Context, teamName string, count int, eligible func(member models.TeamMember, now time.Time) bool) *repository.Rotation {
+	return &repository.Rotation{
+		TeamName: teamName,
+		Pick: func(lastUserID string, members []models.TeamMember) ([]string, error) {
+			now := time.Now()
+			candidateIDs := []string{}
+			for _, member := range members {
+				if eligible(member, now) {
+					candidateIDs = append(candidateIDs, member.UserID)
+				}
+			}
+			picked := nextInRotation(candidateIDs, lastUserID, count)
+			logging.FromContext(ctx).Debug("rotation advanced",
+				"team", teamName,
+				"last_user_id", lastUserID,
+				"candidates", candidateIDs,
+				"reviewers", picked,
+			)
+			return picked, nil
+		},
+	}
+}
+
+// nextInRotation returns up to count candidates that follow lastUserID in
+// user_id order, wrapping around. The cursor is a position in that order
+// rather than an index, so members joining, leaving or becoming unavailable
+// never make the rotation skip or repeat anyone else.
+func nextInRotation(candidateIDs []string, lastUserID string, count int) []string {
+	sorted := make([]string, len(candidateIDs))
+	copy(sorted, candidateIDs)
+	sort.Strings(sorted)
+
+	// First candidate strictly after the cursor
+	start := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i] > lastUserID
+	})
+
+	if count > len(sorted) {
+		count = len(sorted)
+	}
+	picked := make([]string, count)
+	for i := range picked {
+		picked[i] = sorted[(start+i)%len(sorted)]
+	}
+	return picked
+}
@@ -4,52 +4,231 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"pr-reviewer-service/internal/auth"
+	"pr-reviewer-service/internal/logging"
 	"pr-reviewer-service/internal/models"
 	"pr-reviewer-service/internal/repository"
+	"pr-reviewer-service/internal/tracing"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultReviewerCount   = 2
+	maxReviewerCount       = 10
+	defaultReviewWeight    = 1.0
+	defaultAffinityPenalty = 0.5
+	maxAffinityWindow      = 50
+
+	// minPenalizedWeight keeps repeated anti-affinity penalties from
+	// reaching zero, which selection would read as an unset weight
+	minPenalizedWeight = 1e-4
+
+	maxUnassignReasonLength = 500
+
+	// maxVersionAttempts bounds how often a PR update is retried after
+	// losing a race with a concurrent update
+	maxVersionAttempts = 3
+)
+
+// Store is the persistence the service runs on; *repository.Repository
+// implements it
+type Store interface {
+	CreateTeam(ctx context.Context, team *models.Team) error
+	AddTeamMember(ctx context.Context, member *models.MemberChange) (*models.User, error)
+	GetTeam(ctx context.Context, teamName string) (*models.Team, error)
+	GetTeamMembers(ctx context.Context, teamName string) ([]models.TeamMember, error)
+	GetTeamPolicies(ctx context.Context) ([]models.TeamPolicy, error)
+	UpsertTeamPolicy(ctx context.Context, policy *models.TeamPolicy) error
+	ListenTeamPolicyChanges(ctx context.Context, idle time.Duration, wake func(notified bool)) error
+	GetRotationCursor(ctx context.Context, teamName string) (string, error)
+
+	GetUserByID(ctx context.Context, userID string) (models.User, error)
+	UpdateUserActivity(ctx context.Context, userID string, isActive bool) (*models.User, error)
+	SetUserOutOfOffice(ctx context.Context, userID string, until *time.Time) (*models.User, error)
+	RemoveTeamMember(ctx context.Context, userID string) (*models.User, error)
+	GetUserReviewPullRequests(ctx context.Context, userID string, page models.PageRequest) ([]models.PullRequestShort, error)
+
+	CreatePullRequest(ctx context.Context, pr *models.PullRequest, rotation *repository.Rotation) error
+	GetPullRequest(ctx context.Context, prID string) (*models.PullRequest, error)
+	UpdatePullRequest(ctx context.Context, pr *models.PullRequest) error
+	ReplaceReviewer(ctx context.Context, pr *models.PullRequest, replacement *models.Replacement, rotation *repository.Rotation) error
+	GetRecentReviewerCounts(ctx context.Context, authorID string, limit int) (map[string]int, error)
+	CreateReview(ctx context.Context, review *models.Review) error
+
+	GetReviewerStats(ctx context.Context, filter models.StatsFilter) ([]models.ReviewerStats, error)
+	GetPullRequestStats(ctx context.Context, filter models.StatsFilter) (*models.PullRequestStats, error)
+	GetCycleTimeStats(ctx context.Context, filter models.StatsFilter) (*models.CycleTimeStats, error)
+
+	CreateAPIToken(ctx context.Context, token *models.APIToken, tokenHash string) error
+	ListAPITokens(ctx context.Context, page models.PageRequest) ([]models.APIToken, error)
+	RevokeAPIToken(ctx context.Context, tokenID string) (*models.APIToken, error)
+}
+
 type Service struct {
-	repo *repository.Repository
+	repo     Store
+	policies atomic.Pointer[policySnapshot]
+	// reloadMu serializes ReloadPolicies, so a slow reload can't replace
+	// the policies with an older read than a reload that finished first
+	reloadMu sync.Mutex
+
+	mu  sync.Mutex // guards rng
+	rng *rand.Rand
 }
 
-func NewService(repo *repository.Repository) *Service {
-	return &Service{repo: repo}
+// NewService creates a service that selects reviewers with the given strategy
+// for teams without a policy; call ReloadPolicies to load team policies.
+// source seeds models.StrategyRandom selections; pass a fixed source for reproducible runs.
+func NewService(repo Store, source rand.Source, strategy string) *Service {
+	s := &Service{
+		repo: repo,
+		rng:  rand.New(source),
+	}
+	s.policies.Store(&policySnapshot{defaultStrategy: strategy, teams: map[string]models.TeamPolicy{}})
+	return s
 }
 
-func (s *Service) CreateTeam(ctx context.Context, team *models.Team) error {
+func (s *Service) CreateTeam(ctx context.Context, team *models.Team) (err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.CreateTeam")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeTeam(ctx, team.TeamName); err != nil {
+		return err
+	}
+	// Existing users are moved into the new team, which team-leads may only
+	// do with users of teams they lead
+	for _, member := range team.Members {
+		if err := s.authorizeUser(ctx, member.UserID); err != nil && err.Error() != "user not found" {
+			return err
+		}
+	}
+
+	// Members without an explicit weight get the default share
+	for i := range team.Members {
+		if team.Members[i].ReviewWeight <= 0 {
+			team.Members[i].ReviewWeight = defaultReviewWeight
+		}
+	}
 	return s.repo.CreateTeam(ctx, team)
 }
 
-func (s *Service) GetTeam(ctx context.Context, teamName string) (*models.Team, error) {
+func (s *Service) GetTeam(ctx context.Context, teamName string) (_ *models.Team, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.GetTeam")
+	defer func() { tracing.End(span, err) }()
+
 	return s.repo.GetTeam(ctx, teamName)
 }
 
-func (s *Service) UpdateUserActivity(ctx context.Context, userID string, isActive bool) (*models.User, error) {
+func (s *Service) SetTeamPolicy(ctx context.Context, policy *models.TeamPolicy) (err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.SetTeamPolicy")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeTeam(ctx, policy.TeamName); err != nil {
+		return err
+	}
+	if !models.IsValidStrategy(policy.Strategy) {
+		return fmt.Errorf("unknown strategy")
+	}
+	if policy.ReviewerCount == 0 {
+		policy.ReviewerCount = defaultReviewerCount
+	}
+	if policy.ReviewerCount < 0 || policy.ReviewerCount > maxReviewerCount {
+		return fmt.Errorf("invalid reviewer count")
+	}
+	if policy.AffinityPenalty == 0 {
+		policy.AffinityPenalty = defaultAffinityPenalty
+	}
+	if policy.AffinityWindow < 0 || policy.AffinityWindow > maxAffinityWindow ||
+		policy.AffinityPenalty < 0 || policy.AffinityPenalty > 1 {
+		return fmt.Errorf("invalid affinity settings")
+	}
+	if err := s.repo.UpsertTeamPolicy(ctx, policy); err != nil {
+		return err
+	}
+
+	// Other instances pick the change up through the policy watcher; reload
+	// here as well so this instance uses the new policy right away
+	return s.ReloadPolicies(ctx)
+}
+
+// GetTeamPolicy returns the team's policy, filling in service defaults
+// for teams that haven't set one
+func (s *Service) GetTeamPolicy(ctx context.Context, teamName string) (_ *models.TeamPolicy, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.GetTeamPolicy")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.repo.GetTeam(ctx, teamName); err != nil {
+		return nil, err
+	}
+
+	return s.teamPolicy(teamName), nil
+}
+
+// AddTeamMember adds a user to an existing team. A user in another team is
+// moved, which team-leads may only do between teams they lead. Settings
+// member leaves unset keep their current value.
+func (s *Service) AddTeamMember(ctx context.Context, member *models.MemberChange) (_ *models.User, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.AddTeamMember")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeTeam(ctx, member.TeamName); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeUser(ctx, member.UserID); err != nil && err.Error() != "user not found" {
+		return nil, err
+	}
+
+	if member.ReviewWeight != nil && *member.ReviewWeight <= 0 {
+		weight := defaultReviewWeight
+		member.ReviewWeight = &weight
+	}
+	return s.repo.AddTeamMember(ctx, member)
+}
+
+func (s *Service) UpdateUserActivity(ctx context.Context, userID string, isActive bool) (_ *models.User, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.UpdateUserActivity")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.authorizeUser(ctx, userID); err != nil {
+		return nil, err
+	}
 	return s.repo.UpdateUserActivity(ctx, userID, isActive)
 }
 
-func (s *Service) CreatePullRequest(ctx context.Context, prCreate *models.PullRequest) (*models.PullRequest, error) {
-	// Get author info to find team
-	var author models.User
-	// This would need a method to get user by ID - adding to repository
-	author, err := s.repo.GetUserByID(ctx, prCreate.AuthorID)
-	if err != nil {
-		return nil, fmt.Errorf("author not found")
+// RemoveTeamMember takes the user out of their team. They are no longer
+// listed or picked as a reviewer, but keep the reviews they are assigned;
+// AddTeamMember brings them back.
+func (s *Service) RemoveTeamMember(ctx context.Context, userID string) (_ *models.User, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.RemoveTeamMember")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.authorizeUser(ctx, userID); err != nil {
+		return nil, err
 	}
+	return s.repo.RemoveTeamMember(ctx, userID)
+}
 
-	// Get active team members excluding author
-	teamMembers, err := s.repo.GetActiveTeamMembers(ctx, author.TeamName, prCreate.AuthorID)
-	if err != nil {
+func (s *Service) SetUserOutOfOffice(ctx context.Context, userID string, until *time.Time) (_ *models.User, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.SetUserOutOfOffice")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.authorizeUser(ctx, userID); err != nil {
 		return nil, err
 	}
+	return s.repo.SetUserOutOfOffice(ctx, userID, until)
+}
+
+func (s *Service) CreatePullRequest(ctx context.Context, prCreate *models.PullRequest) (_ *models.PullRequest, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.CreatePullRequest")
+	defer func() { tracing.End(span, err) }()
 
-	// Select up to 2 random reviewers
-	reviewers := s.selectRandomReviewers(teamMembers, 2)
-	reviewerIDs := make([]string, len(reviewers))
-	for i, reviewer := range reviewers {
-		reviewerIDs[i] = reviewer.UserID
+	assignment, rotation, err := s.assignReviewers(ctx, prCreate.PullRequestID, prCreate.AuthorID, "", nil, true)
+	if err != nil {
+		return nil, err
 	}
 
 	pr := &models.PullRequest{
@@ -57,11 +236,16 @@ func (s *Service) CreatePullRequest(ctx context.Context, prCreate *models.PullRe
 		PullRequestName:   prCreate.PullRequestName,
 		AuthorID:          prCreate.AuthorID,
 		Status:            "OPEN",
-		AssignedReviewers: reviewerIDs,
+		AssignedReviewers: assignment.Reviewers,
 		CreatedAt:         time.Now(),
+
+		RequestedReviewers: assignment.Requested,
+		AssignmentStrategy: assignment.Strategy,
+		AssignmentSeed:     assignment.Seed,
+		Version:            1,
 	}
 
-	err = s.repo.CreatePullRequest(ctx, pr)
+	err = s.repo.CreatePullRequest(ctx, pr, rotation)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +253,91 @@ func (s *Service) CreatePullRequest(ctx context.Context, prCreate *models.PullRe
 	return pr, nil
 }
 
-func (s *Service) selectRandomReviewers(users []models.User, max int) []models.User {
+// PreviewPullRequest runs the same reviewer selection as CreatePullRequest
+// without persisting anything. An empty strategy uses the author's team policy
+// and a nil seed draws one the way CreatePullRequest would; passing a recorded
+// strategy and seed replays that assignment.
+func (s *Service) PreviewPullRequest(ctx context.Context, prID string, authorID string, strategy string, seed *int64) (_ *models.AssignmentPreview, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.PreviewPullRequest")
+	defer func() { tracing.End(span, err) }()
+
+	preview, _, err := s.assignReviewers(ctx, prID, authorID, strategy, seed, false)
+	return preview, err
+}
+
+// assignReviewers discovers candidates for the author's team and selects the
+// reviewers. Round-robin only previews the rotation unless commit is set;
+// then the reviewers are left for the returned rotation to pick when the PR
+// is stored, so the cursor moves only with the PR.
+func (s *Service) assignReviewers(ctx context.Context, prID string, authorID string, strategy string, seed *int64, commit bool) (*models.AssignmentPreview, *repository.Rotation, error) {
+	// Get author info to find team
+	author, err := s.repo.GetUserByID(ctx, authorID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("author not found")
+	}
+
+	policy := s.teamPolicy(author.TeamName)
+	if strategy == "" {
+		strategy = policy.Strategy
+	}
+	if !models.IsValidStrategy(strategy) {
+		return nil, nil, fmt.Errorf("unknown strategy")
+	}
+
+	var selectionSeed int64
+	if seed != nil {
+		selectionSeed = *seed
+	} else {
+		selectionSeed = s.seedFor(strategy, prID)
+	}
+
+	pool, err := s.discoverCandidates(ctx, author.TeamName, authorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reviewerIDs []string
+	var rotation *repository.Rotation
+	if strategy == models.StrategyRoundRobin && commit {
+		rotation = newRotation(ctx, author.TeamName, policy.ReviewerCount, func(member models.TeamMember, now time.Time) bool {
+			return exclusionReason(member, authorID, now) == ""
+		})
+	} else {
+		candidates, err := s.applyAntiAffinity(ctx, policy, strategy, authorID, pool.Candidates)
+		if err != nil {
+			return nil, nil, err
+		}
+		reviewerIDs, err = s.pickReviewers(ctx, author.TeamName, strategy, selectionSeed, candidates, policy.ReviewerCount)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	logging.FromContext(ctx).Debug("reviewers selected",
+		"pull_request_id", prID,
+		"author_id", authorID,
+		"team", author.TeamName,
+		"strategy", strategy,
+		"seed", selectionSeed,
+		"candidates", pool.candidateIDs(),
+		"excluded", pool.Excluded,
+		"reviewers", reviewerIDs,
+		"dry_run", !commit,
+	)
+
+	return &models.AssignmentPreview{
+		PullRequestID: prID,
+		AuthorID:      authorID,
+		Reviewers:     reviewerIDs,
+		Requested:     policy.ReviewerCount,
+		Candidates:    pool.candidateIDs(),
+		Excluded:      pool.Excluded,
+		Strategy:      strategy,
+		Seed:          selectionSeed,
+	}, rotation, nil
+}
+
+func (s *Service) selectRandomReviewers(rng *rand.Rand, users []models.User, max int) []models.User {
 	if len(users) == 0 {
 		return []models.User{}
 	}
@@ -78,31 +346,58 @@ func (s *Service) selectRandomReviewers(users []models.User, max int) []models.U
 		return users
 	}
 
-	// Shuffle and select
-	shuffled := make([]models.User, len(users))
-	copy(shuffled, users)
-	rand.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	// Weighted sampling without replacement (Efraimidis-Spirakis):
+	// each user gets key u^(1/weight) and the largest keys win
+	type keyedUser struct {
+		user models.User
+		key  float64
+	}
+	keyed := make([]keyedUser, len(users))
+	for i, user := range users {
+		weight := user.ReviewWeight
+		if weight <= 0 {
+			weight = defaultReviewWeight
+		}
+		keyed[i] = keyedUser{user: user, key: math.Pow(rng.Float64(), 1/weight)}
+	}
+	sort.Slice(keyed, func(i, j int) bool {
+		return keyed[i].key > keyed[j].key
 	})
 
-	return shuffled[:max]
+	selected := make([]models.User, max)
+	for i := range selected {
+		selected[i] = keyed[i].user
+	}
+	return selected
 }
 
-func (s *Service) MergePullRequest(ctx context.Context, prID string) (*models.PullRequest, error) {
-	pr, err := s.repo.GetPullRequest(ctx, prID)
-	if err != nil {
-		return nil, err
-	}
+// MergePullRequest marks the PR merged. ifMatch, if not zero, is the
+// version the caller expects the PR to be at.
+func (s *Service) MergePullRequest(ctx context.Context, prID string, ifMatch int64) (_ *models.PullRequest, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.MergePullRequest")
+	defer func() { tracing.End(span, err) }()
+
+	var pr *models.PullRequest
+	err = retryOnVersionConflict(ifMatch, func() error {
+		var err error
+		pr, err = s.repo.GetPullRequest(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(pr, ifMatch); err != nil {
+			return err
+		}
 
-	if pr.Status == "MERGED" {
-		return pr, nil // Idempotent
-	}
+		if pr.Status == "MERGED" {
+			return nil // Idempotent
+		}
 
-	pr.Status = "MERGED"
-	now := time.Now()
-	pr.MergedAt = &now
+		pr.Status = "MERGED"
+		now := time.Now()
+		pr.MergedAt = &now
 
-	err = s.repo.UpdatePullRequest(ctx, pr)
+		return s.repo.UpdatePullRequest(ctx, pr)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -110,25 +405,47 @@ func (s *Service) MergePullRequest(ctx context.Context, prID string) (*models.Pu
 	return pr, nil
 }
 
-func (s *Service) ReassignReviewer(ctx context.Context, prID string, oldUserID string) (*models.PullRequest, string, error) {
+// ReassignReviewer replaces oldUserID on the PR. With authentication
+// enabled, reviewers may decline their own assignment, within the decline
+// quota; only admins and the reviewer's team-lead may reassign others.
+// reason is kept in the assignment history. ifMatch, if not zero, is the
+// version the caller expects the PR to be at.
+func (s *Service) ReassignReviewer(ctx context.Context, prID string, oldUserID string, reason string, ifMatch int64) (_ *models.PullRequest, _ string, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.ReassignReviewer")
+	defer func() { tracing.End(span, err) }()
+
+	if len(reason) > maxUnassignReasonLength {
+		return nil, "", errors.New("reason too long")
+	}
+
+	var pr *models.PullRequest
+	var newReviewerID string
+	err = retryOnVersionConflict(ifMatch, func() error {
+		var err error
+		pr, newReviewerID, err = s.reassignReviewer(ctx, prID, oldUserID, reason, ifMatch)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return pr, newReviewerID, nil
+}
+
+// reassignReviewer makes one attempt at ReassignReviewer from a fresh read of the PR
+func (s *Service) reassignReviewer(ctx context.Context, prID string, oldUserID string, reason string, ifMatch int64) (*models.PullRequest, string, error) {
 	pr, err := s.repo.GetPullRequest(ctx, prID)
 	if err != nil {
 		return nil, "", err
 	}
+	if err := checkIfMatch(pr, ifMatch); err != nil {
+		return nil, "", err
+	}
 
 	if pr.Status == "MERGED" {
 		return nil, "", errors.New("cannot reassign on merged PR")
 	}
 
-	// Check if old user is assigned
-	found := false
-	for _, reviewer := range pr.AssignedReviewers {
-		if reviewer == oldUserID {
-			found = true
-			break
-		}
-	}
-	if !found {
+	if !isAssigned(pr, oldUserID) {
 		return nil, "", errors.New("reviewer is not assigned to this PR")
 	}
 
@@ -138,50 +455,243 @@ func (s *Service) ReassignReviewer(ctx context.Context, prID string, oldUserID s
 		return nil, "", fmt.Errorf("old reviewer not found")
 	}
 
-	// Get available candidates from the same team
-	candidates, err := s.repo.GetActiveTeamMembers(ctx, oldUser.TeamName, oldUserID)
+	unassignment, err := s.authorizeUnassignment(ctx, oldUser, reason)
 	if err != nil {
 		return nil, "", err
 	}
 
+	// Select replacement with the team's policy
+	policy := s.teamPolicy(oldUser.TeamName)
+	replacement := &models.Replacement{
+		OldUserID:    oldUserID,
+		Strategy:     policy.Strategy,
+		Seed:         s.seedFor(policy.Strategy, prID, oldUserID),
+		Unassignment: unassignment,
+	}
+
+	// Round-robin picks inside the version-checked write, so an attempt that
+	// loses a race leaves the cursor where it was
+	var rotation *repository.Rotation
+	if policy.Strategy == models.StrategyRoundRobin {
+		rotation = newRotation(ctx, oldUser.TeamName, 1, func(member models.TeamMember, now time.Time) bool {
+			return exclusionReason(member, pr.AuthorID, now) == "" && !isAssigned(pr, member.UserID)
+		})
+	} else {
+		newReviewerID, err := s.pickReplacement(ctx, pr, oldUser.TeamName, policy, replacement.Strategy, replacement.Seed)
+		if err != nil {
+			return nil, "", err
+		}
+		replacement.NewUserID = newReviewerID
+	}
+
+	err = s.repo.ReplaceReviewer(ctx, pr, replacement, rotation)
+	if err != nil {
+		return nil, "", err
+	}
+
+	logging.FromContext(ctx).Debug("replacement reviewer selected",
+		"pull_request_id", prID,
+		"old_reviewer_id", oldUserID,
+		"new_reviewer_id", replacement.NewUserID,
+		"strategy", policy.Strategy,
+		"seed", replacement.Seed,
+	)
+
+	return pr, replacement.NewUserID, nil
+}
+
+// pickReplacement selects a replacement reviewer for pr with one of the
+// random strategies
+func (s *Service) pickReplacement(ctx context.Context, pr *models.PullRequest, teamName string, policy *models.TeamPolicy, strategy string, seed int64) (string, error) {
+	pool, err := s.discoverCandidates(ctx, teamName, pr.AuthorID)
+	if err != nil {
+		return "", err
+	}
+
 	// Remove candidates already assigned to this PR
 	availableCandidates := []models.User{}
-	for _, candidate := range candidates {
-		alreadyAssigned := false
-		for _, reviewer := range pr.AssignedReviewers {
-			if reviewer == candidate.UserID {
-				alreadyAssigned = true
-				break
-			}
-		}
-		if !alreadyAssigned {
+	for _, candidate := range pool.Candidates {
+		if !isAssigned(pr, candidate.UserID) {
 			availableCandidates = append(availableCandidates, candidate)
 		}
 	}
 
 	if len(availableCandidates) == 0 {
-		return nil, "", errors.New("no active replacement candidate in team")
+		return "", errors.New("no active replacement candidate in team")
+	}
+
+	availableCandidates, err = s.applyAntiAffinity(ctx, policy, strategy, pr.AuthorID, availableCandidates)
+	if err != nil {
+		return "", err
+	}
+	picked, err := s.pickReviewers(ctx, teamName, strategy, seed, availableCandidates, 1)
+	if err != nil {
+		return "", err
+	}
+	return picked[0], nil
+}
+
+func isAssigned(pr *models.PullRequest, userID string) bool {
+	for _, reviewer := range pr.AssignedReviewers {
+		if reviewer == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// retryOnVersionConflict runs attempt again while it loses races with
+// concurrent updates of the PR. Each attempt must read the PR afresh. A
+// caller that named the version it expects (ifMatch) is told about the
+// conflict instead, since its precondition no longer holds.
+func retryOnVersionConflict(ifMatch int64, attempt func() error) error {
+	var err error
+	for i := 0; i < maxVersionAttempts; i++ {
+		err = attempt()
+		if err == nil || err.Error() != "PR version conflict" {
+			return err
+		}
+		if ifMatch != 0 {
+			return errors.New("PR version mismatch")
+		}
+	}
+	return err
+}
+
+// checkIfMatch fails with "PR version mismatch" if ifMatch is set and the
+// PR is at another version
+func checkIfMatch(pr *models.PullRequest, ifMatch int64) error {
+	if ifMatch != 0 && pr.Version != ifMatch {
+		return errors.New("PR version mismatch")
+	}
+	return nil
+}
+
+// authorizeUnassignment checks that the caller may take reviewer off a PR
+// and, if reviewers decline themselves, sets the quota the decline must fit
+func (s *Service) authorizeUnassignment(ctx context.Context, reviewer models.User, reason string) (models.Unassignment, error) {
+	unassignment := models.Unassignment{Reason: reason}
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return unassignment, nil
+	}
+	unassignment.Actor = principal.Actor()
+
+	if principal.UserID != reviewer.UserID {
+		switch principal.Role {
+		case models.RoleAdmin:
+			return unassignment, nil
+		case models.RoleTeamLead:
+			return unassignment, authorizeTeam(ctx, reviewer.TeamName)
+		default:
+			return unassignment, errors.New("forbidden")
+		}
 	}
 
-	// Select random candidate
-	newReviewer := availableCandidates[rand.Intn(len(availableCandidates))]
+	// The quota is checked when the decline is stored, under a lock on
+	// the reviewer, so concurrent declines can't both fit the last slot
+	unassignment.Declined = true
+	quota := s.policies.Load().declineQuota
+	if quota.Limit > 0 {
+		unassignment.DeclineLimit = quota.Limit
+		unassignment.DeclineSince = time.Now().Add(-quota.Period)
+	}
+	return unassignment, nil
+}
+
+// authorizeReview checks the principal may record a review as reviewerID.
+// Readers may only review as themselves and team-leads for their team;
+// admins and bots, which ingest reviews from the code host, for anyone.
+func (s *Service) authorizeReview(ctx context.Context, reviewerID string) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.UserID == reviewerID {
+		return nil
+	}
+	switch principal.Role {
+	case models.RoleAdmin, models.RoleBot:
+		return nil
+	case models.RoleTeamLead:
+		return s.authorizeUser(ctx, reviewerID)
+	default:
+		return errors.New("forbidden")
+	}
+}
+
+// SubmitReview records a review by one of the PR's assigned reviewers
+func (s *Service) SubmitReview(ctx context.Context, prID string, reviewerID string, state string) (_ *models.Review, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.SubmitReview")
+	defer func() { tracing.End(span, err) }()
+
+	switch state {
+	case models.ReviewCommented, models.ReviewApproved, models.ReviewChangesRequested:
+	default:
+		return nil, errors.New("invalid review state")
+	}
+	if err := s.authorizeReview(ctx, reviewerID); err != nil {
+		return nil, err
+	}
 
-	// Replace reviewer
-	for i, reviewer := range pr.AssignedReviewers {
-		if reviewer == oldUserID {
-			pr.AssignedReviewers[i] = newReviewer.UserID
+	pr, err := s.repo.GetPullRequest(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.Status == "MERGED" {
+		return nil, errors.New("cannot review merged PR")
+	}
+
+	found := false
+	for _, reviewer := range pr.AssignedReviewers {
+		if reviewer == reviewerID {
+			found = true
 			break
 		}
 	}
+	if !found {
+		return nil, errors.New("reviewer is not assigned to this PR")
+	}
 
-	err = s.repo.UpdatePullRequest(ctx, pr)
+	review := &models.Review{
+		PullRequestID: prID,
+		ReviewerID:    reviewerID,
+		State:         state,
+		SubmittedAt:   time.Now(),
+	}
+	if err := s.repo.CreateReview(ctx, review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// GetUserReviewPullRequests returns a page of the PRs the user reviews and
+// the cursor of the next page, empty on the last one
+func (s *Service) GetUserReviewPullRequests(ctx context.Context, userID string, page models.PageRequest) (_ []models.PullRequestShort, _ string, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.GetUserReviewPullRequests")
+	defer func() { tracing.End(span, err) }()
+
+	prs, err := s.repo.GetUserReviewPullRequests(ctx, userID, peekNextPage(page))
 	if err != nil {
 		return nil, "", err
 	}
+	prs, next := splitPage(prs, page, func(pr models.PullRequestShort) string { return pr.PullRequestID })
+	return prs, next, nil
+}
 
-	return pr, newReviewer.UserID, nil
+// peekNextPage asks for one item more than page, to tell whether another
+// page follows
+func peekNextPage(page models.PageRequest) models.PageRequest {
+	if page.Limit > 0 {
+		page.Limit++
+	}
+	return page
 }
 
-func (s *Service) GetUserReviewPullRequests(ctx context.Context, userID string) ([]models.PullRequestShort, error) {
-	return s.repo.GetUserReviewPullRequests(ctx, userID)
+// splitPage trims items fetched with peekNextPage to the page and returns
+// the cursor of the next page, or an empty one if there is none
+func splitPage[T any](items []T, page models.PageRequest, id func(T) string) ([]T, string) {
+	if page.Limit == 0 || len(items) <= page.Limit {
+		return items, ""
+	}
+	items = items[:page.Limit]
+	return items, id(items[len(items)-1])
 }
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"pr-reviewer-service/internal/auth"
+	"pr-reviewer-service/internal/models"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSelectRandomReviewersFollowsWeights(t *testing.T) {
+	s := &Service{}
+	rng := rand.New(rand.NewSource(42))
+	users := []models.User{
+		{UserID: "u1", ReviewWeight: 1},
+		{UserID: "u2", ReviewWeight: 2},
+		{UserID: "u3", ReviewWeight: 3},
+		{UserID: "u4", ReviewWeight: 0.5},
+	}
+	totalWeight := 6.5
+
+	const trials = 65000
+	counts := map[string]int{}
+	for i := 0; i < trials; i++ {
+		selected := s.selectRandomReviewers(rng, users, 1)
+		if len(selected) != 1 {
+			t.Fatalf("expected 1 reviewer, got %d", len(selected))
+		}
+		counts[selected[0].UserID]++
+	}
+
+	// Pearson's chi-squared goodness of fit; 16.27 is the critical
+	// value for 3 degrees of freedom at p = 0.001
+	chiSquared := 0.0
+	for _, user := range users {
+		expected := trials * user.ReviewWeight / totalWeight
+		diff := float64(counts[user.UserID]) - expected
+		chiSquared += diff * diff / expected
+	}
+	if chiSquared > 16.27 {
+		t.Errorf("selection does not follow weights: chi^2 = %.2f, counts = %v", chiSquared, counts)
+	}
+}
+
+func TestSelectRandomReviewersReturnsDistinctUsers(t *testing.T) {
+	s := &Service{}
+	rng := rand.New(rand.NewSource(42))
+	users := []models.User{
+		{UserID: "u1", ReviewWeight: 1},
+		{UserID: "u2", ReviewWeight: 5},
+		{UserID: "u3", ReviewWeight: 1},
+	}
+
+	for i := 0; i < 1000; i++ {
+		selected := s.selectRandomReviewers(rng, users, 2)
+		if len(selected) != 2 {
+			t.Fatalf("expected 2 reviewers, got %d", len(selected))
+		}
+		if selected[0].UserID == selected[1].UserID {
+			t.Fatalf("reviewer %s selected twice", selected[0].UserID)
+		}
+	}
+}
+
+func TestPRSeededSelectionIsReproducible(t *testing.T) {
+	s := NewService(nil, rand.NewSource(1), models.StrategyPRSeeded)
+	users := []models.User{
+		{UserID: "u1", ReviewWeight: 1},
+		{UserID: "u2", ReviewWeight: 1},
+		{UserID: "u3", ReviewWeight: 1},
+		{UserID: "u4", ReviewWeight: 1},
+	}
+
+	seed := s.seedFor(models.StrategyPRSeeded, "pr-1001")
+	first := s.selectRandomReviewers(newSelectionRand(seed), users, 2)
+	for i := 0; i < 100; i++ {
+		replaySeed := s.seedFor(models.StrategyPRSeeded, "pr-1001")
+		if replaySeed != seed {
+			t.Fatalf("seed changed between calls: %d != %d", replaySeed, seed)
+		}
+		replay := s.selectRandomReviewers(newSelectionRand(replaySeed), users, 2)
+		if replay[0].UserID != first[0].UserID || replay[1].UserID != first[1].UserID {
+			t.Fatalf("replay picked %s,%s, want %s,%s",
+				replay[0].UserID, replay[1].UserID, first[0].UserID, first[1].UserID)
+		}
+	}
+}
+
+func TestNextInRotationWrapsAndSurvivesMembershipChanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		last       string
+		count      int
+		want       []string
+	}{
+		{"fresh rotation", []string{"u3", "u1", "u2"}, "", 2, []string{"u1", "u2"}},
+		{"wraps around", []string{"u1", "u2", "u3"}, "u2", 2, []string{"u3", "u1"}},
+		{"cursor user left", []string{"u1", "u3", "u4"}, "u2", 1, []string{"u3"}},
+		{"new member joins behind cursor", []string{"u1", "u2", "u2a", "u3"}, "u2", 2, []string{"u2a", "u3"}},
+		{"fewer candidates than requested", []string{"u1"}, "u1", 2, []string{"u1"}},
+		{"no candidates", []string{}, "u1", 2, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextInRotation(tt.candidates, tt.last, tt.count)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPenalizeRecentReviewers(t *testing.T) {
+	candidates := []models.User{
+		{UserID: "u1", ReviewWeight: 2},
+		{UserID: "u2", ReviewWeight: 1},
+		{UserID: "u3"},
+	}
+	recent := map[string]int{"u1": 2, "u3": 1}
+
+	adjusted := penalizeRecentReviewers(candidates, recent, 0.5)
+
+	want := map[string]float64{"u1": 0.5, "u2": 1, "u3": 0.5}
+	for _, candidate := range adjusted {
+		if candidate.ReviewWeight != want[candidate.UserID] {
+			t.Errorf("%s: weight %v, want %v", candidate.UserID, candidate.ReviewWeight, want[candidate.UserID])
+		}
+	}
+	if candidates[0].ReviewWeight != 2 {
+		t.Errorf("input candidates were modified")
+	}
+}
+
+func TestPenalizeRecentReviewersKeepsWeightsPositive(t *testing.T) {
+	candidates := []models.User{{UserID: "u1", ReviewWeight: 1}, {UserID: "u2", ReviewWeight: 1}}
+	recent := map[string]int{"u1": maxAffinityWindow}
+
+	adjusted := penalizeRecentReviewers(candidates, recent, 1e-10)
+	if adjusted[0].ReviewWeight != minPenalizedWeight {
+		t.Fatalf("penalized weight %v, want the floor %v", adjusted[0].ReviewWeight, minPenalizedWeight)
+	}
+
+	// Treated as an unset weight, u1 would be as likely as u2
+	rng := rand.New(rand.NewSource(1))
+	picks := 0
+	for i := 0; i < 1000; i++ {
+		if s := (&Service{}).selectRandomReviewers(rng, adjusted, 1); s[0].UserID == "u1" {
+			picks++
+		}
+	}
+	if picks > 10 {
+		t.Errorf("penalized reviewer picked %d times out of 1000", picks)
+	}
+}
+
+func TestAntiAffinityFollowsEffectiveStrategy(t *testing.T) {
+	tests := []struct {
+		policy, preview string
+		wantLookup      bool
+	}{
+		{models.StrategyRoundRobin, models.StrategyRandom, true},
+		{models.StrategyRandom, models.StrategyRoundRobin, false},
+		{models.StrategyRandom, "", true},
+		{models.StrategyRoundRobin, "", false},
+	}
+
+	for _, tt := range tests {
+		store := roundRobinTeam()
+		s := NewService(store, rand.NewSource(1), models.StrategyRandom)
+		s.updatePolicies(func(next *policySnapshot) {
+			next.teams = map[string]models.TeamPolicy{"backend": {
+				TeamName: "backend", Strategy: tt.policy, ReviewerCount: 2,
+				AffinityWindow: 5, AffinityPenalty: defaultAffinityPenalty,
+			}}
+		})
+
+		if _, err := s.PreviewPullRequest(context.Background(), "pr-1", "u1", tt.preview, nil); err != nil {
+			t.Fatalf("policy %s, preview %q: %v", tt.policy, tt.preview, err)
+		}
+		if got := store.recentLookups > 0; got != tt.wantLookup {
+			t.Errorf("policy %s, preview %q: anti-affinity applied %v, want %v", tt.policy, tt.preview, got, tt.wantLookup)
+		}
+	}
+}
+
+func roundRobinTeam() *fakeStore {
+	return newFakeStore(
+		models.User{UserID: "u1", TeamName: "backend", IsActive: true},
+		models.User{UserID: "u2", TeamName: "backend", IsActive: true},
+		models.User{UserID: "u3", TeamName: "backend", IsActive: true},
+		models.User{UserID: "u4", TeamName: "backend", IsActive: true},
+	)
+}
+
+func TestRoundRobinCursorMovesOnlyWithCreatedPR(t *testing.T) {
+	store := roundRobinTeam()
+	s := NewService(store, rand.NewSource(1), models.StrategyRoundRobin)
+	ctx := context.Background()
+
+	store.createErr = errors.New("PR id already exists")
+	if _, err := s.CreatePullRequest(ctx, &models.PullRequest{PullRequestID: "pr-1", AuthorID: "u1"}); err == nil {
+		t.Fatal("expected the create to fail")
+	}
+	if cursor := store.cursors["backend"]; cursor != "" {
+		t.Fatalf("failed create moved the cursor to %q", cursor)
+	}
+
+	store.createErr = nil
+	pr, err := s.CreatePullRequest(ctx, &models.PullRequest{PullRequestID: "pr-1", AuthorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pr.AssignedReviewers) != 2 || pr.AssignedReviewers[0] != "u2" || pr.AssignedReviewers[1] != "u3" {
+		t.Errorf("expected reviewers [u2 u3], got %v", pr.AssignedReviewers)
+	}
+	if cursor := store.cursors["backend"]; cursor != "u3" {
+		t.Errorf("expected the cursor at u3, got %q", cursor)
+	}
+}
+
+func TestCreateTeamChecksTeamLeadOwnsMovedMembers(t *testing.T) {
+	store := newFakeStore(
+		models.User{UserID: "u1", TeamName: "backend"},
+		models.User{UserID: "u2", TeamName: "payments"},
+	)
+	s := NewService(store, rand.NewSource(1), models.StrategyRandom)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Role: models.RoleTeamLead, TeamName: "mobile"})
+
+	team := &models.Team{TeamName: "mobile", Members: []models.User{{UserID: "u2"}, {UserID: "u3"}}}
+	if err := s.CreateTeam(ctx, team); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("expected forbidden for a member of another team, got %v", err)
+	}
+	if store.users["u2"].TeamName != "payments" {
+		t.Errorf("member was moved despite the error")
+	}
+
+	team = &models.Team{TeamName: "mobile", Members: []models.User{{UserID: "u3"}}}
+	if err := s.CreateTeam(ctx, team); err != nil {
+		t.Fatalf("new users may be added: %v", err)
+	}
+}
+
+func TestReassignRetriesVersionConflictsWithoutMovingCursor(t *testing.T) {
+	store := roundRobinTeam()
+	store.prs["pr-1"] = &models.PullRequest{PullRequestID: "pr-1", AuthorID: "u1", Status: "OPEN",
+		AssignedReviewers: []string{"u2", "u3"}, Version: 1}
+	store.conflicts = 2
+	s := NewService(store, rand.NewSource(1), models.StrategyRoundRobin)
+
+	pr, newReviewerID, err := s.ReassignReviewer(context.Background(), "pr-1", "u2", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newReviewerID != "u4" || pr.AssignedReviewers[0] != "u4" || pr.Version != 2 {
+		t.Errorf("unexpected result %s, %+v", newReviewerID, pr)
+	}
+	if store.writes != 3 {
+		t.Errorf("expected 3 attempts, got %d", store.writes)
+	}
+	if cursor := store.cursors["backend"]; cursor != "u4" {
+		t.Errorf("expected the cursor to move once, to u4, got %q", cursor)
+	}
+}
+
+func TestVersionConflicts(t *testing.T) {
+	operations := map[string]func(s *Service, ifMatch int64) error{
+		"merge": func(s *Service, ifMatch int64) error {
+			_, err := s.MergePullRequest(context.Background(), "pr-1", ifMatch)
+			return err
+		},
+		"reassign": func(s *Service, ifMatch int64) error {
+			_, _, err := s.ReassignReviewer(context.Background(), "pr-1", "u2", "", ifMatch)
+			return err
+		},
+	}
+	tests := []struct {
+		name       string
+		ifMatch    int64
+		conflicts  int
+		wantErr    string
+		wantWrites int
+	}{
+		// Without If-Match the service retries, then gives up with a 409
+		{"retries exhausted", 0, maxVersionAttempts, "PR version conflict", maxVersionAttempts},
+		// With If-Match a lost race means the precondition failed: 412
+		{"race with If-Match", 1, 1, "PR version mismatch", 1},
+		{"stale If-Match", 2, 0, "PR version mismatch", 0},
+	}
+
+	for name, operation := range operations {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				store := roundRobinTeam()
+				store.prs["pr-1"] = &models.PullRequest{PullRequestID: "pr-1", AuthorID: "u1", Status: "OPEN",
+					AssignedReviewers: []string{"u2", "u3"}, Version: 1}
+				store.conflicts = tt.conflicts
+				s := NewService(store, rand.NewSource(1), models.StrategyRoundRobin)
+
+				err := operation(s, tt.ifMatch)
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected %q, got %v", tt.wantErr, err)
+				}
+				if store.writes != tt.wantWrites {
+					t.Errorf("expected %d writes, got %d", tt.wantWrites, store.writes)
+				}
+				if store.prs["pr-1"].Version != 1 {
+					t.Errorf("PR was updated")
+				}
+				if cursor := store.cursors["backend"]; cursor != "" {
+					t.Errorf("failed update moved the cursor to %q", cursor)
+				}
+			})
+		}
+	}
+}
+
+func TestServiceSpansRecordErrors(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	s := NewService(newFakeStore(), rand.NewSource(1), models.StrategyRandom)
+	now := time.Now()
+	if _, err := s.GetReviewerStats(context.Background(), models.StatsFilter{From: &now, To: &now}); err == nil {
+		t.Fatal("expected an invalid time range error")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error || spans[0].Status().Description != "invalid time range" {
+		t.Errorf("span status %+v, want the error", spans[0].Status())
+	}
+	if len(spans[0].Events()) != 1 || spans[0].Events()[0].Name != "exception" {
+		t.Errorf("span events %+v, want the recorded error", spans[0].Events())
+	}
+}
+
+func TestSubmitReviewChecksReviewer(t *testing.T) {
+	store := roundRobinTeam()
+	store.users["u5"] = models.User{UserID: "u5", TeamName: "payments", IsActive: true}
+	store.prs["pr-1"] = &models.PullRequest{PullRequestID: "pr-1", AuthorID: "u1", Status: "OPEN",
+		AssignedReviewers: []string{"u2", "u5"}, Version: 1}
+	s := NewService(store, rand.NewSource(1), models.StrategyRandom)
+
+	tests := []struct {
+		name       string
+		principal  auth.Principal
+		reviewerID string
+		wantErr    string
+	}{
+		{"reader for themselves", auth.Principal{Role: models.RoleReader, UserID: "u2"}, "u2", ""},
+		{"reader for another user", auth.Principal{Role: models.RoleReader, UserID: "u3"}, "u2", "forbidden"},
+		{"reader without a user", auth.Principal{Role: models.RoleReader}, "u2", "forbidden"},
+		{"team-lead for their team", auth.Principal{Role: models.RoleTeamLead, TeamName: "backend"}, "u2", ""},
+		{"team-lead for another team", auth.Principal{Role: models.RoleTeamLead, TeamName: "backend"}, "u5", "forbidden"},
+		{"bot", auth.Principal{Role: models.RoleBot}, "u5", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal := tt.principal
+			ctx := auth.WithPrincipal(context.Background(), &principal)
+			_, err := s.SubmitReview(ctx, "pr-1", tt.reviewerID, models.ReviewApproved)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("expected %s, got %v", tt.wantErr, err)
+			}
+		})
+	}
+	if len(store.reviews) != 3 {
+		t.Errorf("stored %d reviews, want 3", len(store.reviews))
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/tracing"
+)
+
+func (s *Service) GetReviewerStats(ctx context.Context, filter models.StatsFilter) (_ []models.ReviewerStats, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.GetReviewerStats")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateStatsFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetReviewerStats(ctx, filter)
+}
+
+func (s *Service) GetPullRequestStats(ctx context.Context, filter models.StatsFilter) (_ *models.PullRequestStats, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.GetPullRequestStats")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateStatsFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetPullRequestStats(ctx, filter)
+}
+
+func validateStatsFilter(filter models.StatsFilter) error {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return fmt.Errorf("invalid time range")
+	}
+	return nil
+}
+
+func (s *Service) GetCycleTimeStats(ctx context.Context, filter models.StatsFilter) (_ *models.CycleTimeStats, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "Service.GetCycleTimeStats")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateStatsFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCycleTimeStats(ctx, filter)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/repository"
+	"sort"
+)
+
+// fakeStore keeps users, PRs and rotation cursors in memory. Writes apply
+// all of their changes or none, like the repository's transactions. Store
+// methods the tests don't need are left to the nil embedded Store.
+type fakeStore struct {
+	Store
+
+	users   map[string]models.User
+	prs     map[string]*models.PullRequest
+	cursors map[string]string
+	reviews []models.Review
+
+	// createErr fails CreatePullRequest after the rotation has picked
+	createErr error
+	// conflicts is how many of the next PR updates lose a race with a
+	// concurrent update; writes counts the updates attempted
+	conflicts int
+	writes    int
+	// recentLookups counts anti-affinity lookups of recent reviewers
+	recentLookups int
+}
+
+func newFakeStore(users ...models.User) *fakeStore {
+	f := &fakeStore{
+		users:   map[string]models.User{},
+		prs:     map[string]*models.PullRequest{},
+		cursors: map[string]string{},
+	}
+	for _, user := range users {
+		f.users[user.UserID] = user
+	}
+	return f
+}
+
+func (f *fakeStore) CreateTeam(ctx context.Context, team *models.Team) error {
+	for _, member := range team.Members {
+		member.TeamName = team.TeamName
+		f.users[member.UserID] = member
+	}
+	return nil
+}
+
+func (f *fakeStore) GetUserByID(ctx context.Context, userID string) (models.User, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return user, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func (f *fakeStore) GetTeamMembers(ctx context.Context, teamName string) ([]models.TeamMember, error) {
+	var members []models.TeamMember
+	for _, user := range f.users {
+		if user.TeamName == teamName {
+			members = append(members, models.TeamMember{User: user})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+	return members, nil
+}
+
+func (f *fakeStore) GetRecentReviewerCounts(ctx context.Context, authorID string, limit int) (map[string]int, error) {
+	f.recentLookups++
+	return map[string]int{}, nil
+}
+
+func (f *fakeStore) GetRotationCursor(ctx context.Context, teamName string) (string, error) {
+	return f.cursors[teamName], nil
+}
+
+// rotate returns the rotation's picks without moving the cursor
+func (f *fakeStore) rotate(ctx context.Context, rotation *repository.Rotation) ([]string, error) {
+	members, _ := f.GetTeamMembers(ctx, rotation.TeamName)
+	return rotation.Pick(f.cursors[rotation.TeamName], members)
+}
+
+func (f *fakeStore) commitRotation(rotation *repository.Rotation, picked []string) {
+	if rotation != nil && len(picked) > 0 {
+		f.cursors[rotation.TeamName] = picked[len(picked)-1]
+	}
+}
+
+func (f *fakeStore) CreatePullRequest(ctx context.Context, pr *models.PullRequest, rotation *repository.Rotation) error {
+	var picked []string
+	if rotation != nil {
+		var err error
+		if picked, err = f.rotate(ctx, rotation); err != nil {
+			return err
+		}
+	}
+	if f.createErr != nil {
+		return f.createErr
+	}
+	if _, ok := f.prs[pr.PullRequestID]; ok {
+		return errors.New("PR id already exists")
+	}
+
+	if rotation != nil {
+		pr.AssignedReviewers = picked
+	}
+	f.commitRotation(rotation, picked)
+	stored := *pr
+	f.prs[pr.PullRequestID] = &stored
+	return nil
+}
+
+func (f *fakeStore) GetPullRequest(ctx context.Context, prID string) (*models.PullRequest, error) {
+	pr, ok := f.prs[prID]
+	if !ok {
+		return nil, errors.New("PR not found")
+	}
+	copied := *pr
+	copied.AssignedReviewers = append([]string(nil), pr.AssignedReviewers...)
+	return &copied, nil
+}
+
+func (f *fakeStore) CreateReview(ctx context.Context, review *models.Review) error {
+	f.reviews = append(f.reviews, *review)
+	return nil
+}
+
+func (f *fakeStore) ReplaceReviewer(ctx context.Context, pr *models.PullRequest, replacement *models.Replacement, rotation *repository.Rotation) error {
+	var picked []string
+	if rotation != nil {
+		var err error
+		if picked, err = f.rotate(ctx, rotation); err != nil {
+			return err
+		}
+		if len(picked) == 0 {
+			return errors.New("no active replacement candidate in team")
+		}
+		replacement.NewUserID = picked[0]
+	}
+
+	stored, err := f.checkVersion(pr)
+	if err != nil {
+		return err
+	}
+
+	for i, reviewer := range pr.AssignedReviewers {
+		if reviewer == replacement.OldUserID {
+			pr.AssignedReviewers[i] = replacement.NewUserID
+		}
+	}
+	pr.Version++
+	f.commitRotation(rotation, picked)
+	stored.AssignedReviewers = append([]string(nil), pr.AssignedReviewers...)
+	stored.Version = pr.Version
+	return nil
+}
+
+func (f *fakeStore) UpdatePullRequest(ctx context.Context, pr *models.PullRequest) error {
+	stored, err := f.checkVersion(pr)
+	if err != nil {
+		return err
+	}
+	pr.Version++
+	*stored = *pr
+	stored.AssignedReviewers = append([]string(nil), pr.AssignedReviewers...)
+	return nil
+}
+
+// checkVersion counts a write of pr and fails it if a conflict is pending
+// or the stored PR moved on from pr.Version
+func (f *fakeStore) checkVersion(pr *models.PullRequest) (*models.PullRequest, error) {
+	f.writes++
+	stored := f.prs[pr.PullRequestID]
+	if f.conflicts > 0 {
+		f.conflicts--
+		return nil, errors.New("PR version conflict")
+	}
+	if stored.Version != pr.Version {
+		return nil, errors.New("PR version conflict")
+	}
+	return stored, nil
+}
@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Group runs the service's background workers, tracks their heartbeats
+// and stops them together
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	workers map[string]*state
+}
+
+type state struct {
+	staleAfter    time.Duration
+	critical      bool
+	running       bool
+	lastHeartbeat time.Time
+}
+
+// Status describes one worker for health reporting
+type Status struct {
+	Name          string    `json:"name"`
+	Running       bool      `json:"running"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Healthy       bool      `json:"healthy"`
+	// Critical workers are needed to serve requests correctly
+	Critical bool `json:"critical"`
+}
+
+type heartbeatKey struct{}
+
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel, workers: map[string]*state{}}
+}
+
+// Go runs fn in its own goroutine. fn must return once its context is done
+// and call Heartbeat with that context at least every staleAfter, otherwise
+// the worker is reported unhealthy.
+func (g *Group) Go(name string, staleAfter time.Duration, fn func(ctx context.Context)) {
+	g.start(name, staleAfter, false, fn)
+}
+
+// GoCritical runs fn like Go, for a worker that requests depend on, such as
+// one keeping shared state current. Readiness fails while it is unhealthy.
+func (g *Group) GoCritical(name string, staleAfter time.Duration, fn func(ctx context.Context)) {
+	g.start(name, staleAfter, true, fn)
+}
+
+func (g *Group) start(name string, staleAfter time.Duration, critical bool, fn func(ctx context.Context)) {
+	g.mu.Lock()
+	st := &state{staleAfter: staleAfter, critical: critical, running: true, lastHeartbeat: time.Now()}
+	g.workers[name] = st
+	g.mu.Unlock()
+
+	ctx := context.WithValue(g.ctx, heartbeatKey{}, func() {
+		g.mu.Lock()
+		st.lastHeartbeat = time.Now()
+		g.mu.Unlock()
+	})
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			g.mu.Lock()
+			st.running = false
+			g.mu.Unlock()
+		}()
+
+		slog.Info("Worker started", "worker", name)
+		fn(ctx)
+		slog.Info("Worker stopped", "worker", name)
+	}()
+}
+
+// Heartbeat marks the worker running with ctx as alive. It does nothing for
+// contexts that don't belong to a Group worker.
+func Heartbeat(ctx context.Context) {
+	if beat, ok := ctx.Value(heartbeatKey{}).(func()); ok {
+		beat()
+	}
+}
+
+// Status reports every worker, sorted by name
+func (g *Group) Status() []Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]Status, 0, len(g.workers))
+	for name, st := range g.workers {
+		statuses = append(statuses, Status{
+			Name:          name,
+			Running:       st.running,
+			LastHeartbeat: st.lastHeartbeat,
+			Healthy:       st.running && now.Sub(st.lastHeartbeat) <= st.staleAfter,
+			Critical:      st.critical,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Stop cancels every worker and waits for them to return, or for ctx to be done
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,16 @@
+ALTER TABLE pull_requests ADD COLUMN assignment_strategy VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE pull_requests ADD COLUMN assignment_seed BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE reviewer_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    reviewer_id VARCHAR(255) NOT NULL REFERENCES users(user_id),
+    strategy VARCHAR(50) NOT NULL,
+    seed BIGINT NOT NULL,
+    assigned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    unassigned_at TIMESTAMP NULL,
+    replaced_by VARCHAR(255) NULL REFERENCES users(user_id)
+);
+
+CREATE INDEX idx_assignments_pr ON reviewer_assignments(pull_request_id);
+CREATE INDEX idx_assignments_reviewer ON reviewer_assignments(reviewer_id);
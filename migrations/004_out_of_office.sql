@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN out_of_office_until TIMESTAMP NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE team_policies ADD COLUMN affinity_window INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE team_policies ADD COLUMN affinity_penalty DOUBLE PRECISION NOT NULL DEFAULT 0.5;
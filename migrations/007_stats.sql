@@ -0,0 +1,8 @@
+-- Assignments made before reviewer_assignments existed
+INSERT INTO reviewer_assignments (pull_request_id, reviewer_id, strategy, seed, assigned_at)
+SELECT pr.pull_request_id, reviewer.id, pr.assignment_strategy, pr.assignment_seed, pr.created_at
+FROM pull_requests pr, jsonb_array_elements_text(pr.assigned_reviewers) AS reviewer(id)
+WHERE NOT EXISTS (SELECT 1 FROM reviewer_assignments a WHERE a.pull_request_id = pr.pull_request_id);
+
+CREATE INDEX idx_assignments_assigned_at ON reviewer_assignments(assigned_at);
+CREATE INDEX idx_pr_created_at ON pull_requests(created_at);
@@ -0,0 +1 @@
+ALTER TABLE pull_requests ADD COLUMN requested_reviewers INTEGER NOT NULL DEFAULT 2;
@@ -0,0 +1,20 @@
+ALTER TABLE team_policies ADD COLUMN reviewer_count INTEGER NOT NULL DEFAULT 2;
+
+-- Running instances LISTEN on team_policies_changed and reload their cached
+-- policies when a team's policy is set or removed
+CREATE OR REPLACE FUNCTION notify_team_policies_changed() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('team_policies_changed', OLD.team_name);
+    ELSE
+        PERFORM pg_notify('team_policies_changed', NEW.team_name);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER team_policies_changed
+    AFTER INSERT OR UPDATE OR DELETE ON team_policies
+    FOR EACH ROW EXECUTE FUNCTION notify_team_policies_changed();
+
+INSERT INTO schema_migrations (version) VALUES (11);
@@ -0,0 +1,11 @@
+CREATE TABLE api_tokens (
+    token_id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(20) NOT NULL,
+    team_name VARCHAR(255) NULL REFERENCES teams(team_name) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL
+);
+
+INSERT INTO schema_migrations (version) VALUES (12);
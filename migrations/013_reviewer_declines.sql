@@ -0,0 +1,7 @@
+ALTER TABLE reviewer_assignments ADD COLUMN unassigned_by VARCHAR(255) NULL;
+ALTER TABLE reviewer_assignments ADD COLUMN unassign_reason TEXT NULL;
+ALTER TABLE reviewer_assignments ADD COLUMN declined BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_assignments_declines ON reviewer_assignments(reviewer_id, unassigned_at) WHERE declined;
+
+INSERT INTO schema_migrations (version) VALUES (13);
@@ -0,0 +1,10 @@
+-- Token buckets shared by all replicas when RATE_LIMIT_BACKEND=postgres.
+-- Unlogged: losing them in a crash only resets the budgets.
+CREATE UNLOGGED TABLE rate_limit_buckets (
+    bucket_key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO schema_migrations (version) VALUES (14);
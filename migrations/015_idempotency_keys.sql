@@ -0,0 +1,17 @@
+-- Responses of POST requests sent with an Idempotency-Key header, replayed
+-- to retries. scope is the caller, so clients can't see each other's keys.
+CREATE TABLE idempotency_keys (
+    scope VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER NULL,
+    content_type VARCHAR(255) NULL,
+    response_body BYTEA NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+INSERT INTO schema_migrations (version) VALUES (15);
@@ -0,0 +1,5 @@
+-- Optimistic locking: updates only apply if the PR is still at the version
+-- they read, and increment it
+ALTER TABLE pull_requests ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+INSERT INTO schema_migrations (version) VALUES (16);
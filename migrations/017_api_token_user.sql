@@ -0,0 +1,5 @@
+-- API tokens may be linked to a user, so callers with a token can act as
+-- that user, e.g. decline their own review assignments
+ALTER TABLE api_tokens ADD COLUMN user_id VARCHAR(255) NULL REFERENCES users(user_id) ON DELETE CASCADE;
+
+INSERT INTO schema_migrations (version) VALUES (17);
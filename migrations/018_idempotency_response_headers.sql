@@ -0,0 +1,6 @@
+-- Replayed responses keep the headers clients act on: ETag for If-Match
+-- and Retry-After for when to try again
+ALTER TABLE idempotency_keys ADD COLUMN etag VARCHAR(255) NULL;
+ALTER TABLE idempotency_keys ADD COLUMN retry_after VARCHAR(32) NULL;
+
+INSERT INTO schema_migrations (version) VALUES (18);
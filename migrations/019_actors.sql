@@ -0,0 +1,8 @@
+-- Who made each change, as recorded for reviewer unassignments: the user ID
+-- or "token:<id>" of the caller, NULL when authentication is disabled
+ALTER TABLE pull_requests ADD COLUMN created_by VARCHAR(255) NULL;
+ALTER TABLE pull_requests ADD COLUMN merged_by VARCHAR(255) NULL;
+ALTER TABLE users ADD COLUMN updated_by VARCHAR(255) NULL;
+ALTER TABLE team_policies ADD COLUMN updated_by VARCHAR(255) NULL;
+
+INSERT INTO schema_migrations (version) VALUES (19);
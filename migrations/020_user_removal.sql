@@ -0,0 +1,5 @@
+-- Removed members are kept, so the PRs they wrote and reviewed stay
+-- attributed, but no longer belong to their team
+ALTER TABLE users ADD COLUMN removed_at TIMESTAMP NULL;
+
+INSERT INTO schema_migrations (version) VALUES (20);
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// AddTeam creates a team with its members
+func (c *Client) AddTeam(ctx context.Context, team Team, opts ...CallOption) (*Team, error) {
+	var resp struct {
+		Team Team `json:"team"`
+	}
+	if err := c.post(ctx, "/team/add", team, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.Team, nil
+}
+
+// AddTeamMember adds a user to an existing team, moving them if they are
+// in another one. Settings member leaves nil keep their current value.
+func (c *Client) AddTeamMember(ctx context.Context, member MemberChange, opts ...CallOption) (*User, error) {
+	return c.postUser(ctx, "/team/addMember", member, opts)
+}
+
+// RemoveTeamMember takes a user out of their team; they stay in the PR
+// history, and AddTeamMember brings them back
+func (c *Client) RemoveTeamMember(ctx context.Context, userID string, opts ...CallOption) (*User, error) {
+	req := struct {
+		UserID string `json:"user_id"`
+	}{userID}
+	return c.postUser(ctx, "/team/removeMember", req, opts)
+}
+
+func (c *Client) GetTeam(ctx context.Context, teamName string) (*Team, error) {
+	var team Team
+	if err := c.get(ctx, "/team/get", url.Values{"team_name": {teamName}}, &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// SetTeamPolicy stores the team's reviewer selection policy and returns it
+// with defaults filled in
+func (c *Client) SetTeamPolicy(ctx context.Context, policy TeamPolicy, opts ...CallOption) (*TeamPolicy, error) {
+	var resp struct {
+		Policy TeamPolicy `json:"policy"`
+	}
+	if err := c.post(ctx, "/team/setPolicy", policy, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.Policy, nil
+}
+
+// GetTeamPolicy returns the policy the team uses, which are the service
+// defaults if none was set
+func (c *Client) GetTeamPolicy(ctx context.Context, teamName string) (*TeamPolicy, error) {
+	var resp struct {
+		Policy TeamPolicy `json:"policy"`
+	}
+	if err := c.get(ctx, "/team/getPolicy", url.Values{"team_name": {teamName}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Policy, nil
+}
+
+func (c *Client) SetUserActive(ctx context.Context, userID string, active bool, opts ...CallOption) (*User, error) {
+	req := struct {
+		UserID   string `json:"user_id"`
+		IsActive bool   `json:"is_active"`
+	}{userID, active}
+	return c.postUser(ctx, "/users/setIsActive", req, opts)
+}
+
+// SetUserOutOfOffice keeps the user from being picked as a reviewer until
+// the given time; nil clears it
+func (c *Client) SetUserOutOfOffice(ctx context.Context, userID string, until *time.Time, opts ...CallOption) (*User, error) {
+	req := struct {
+		UserID string     `json:"user_id"`
+		Until  *time.Time `json:"until"`
+	}{userID, until}
+	return c.postUser(ctx, "/users/setOutOfOffice", req, opts)
+}
+
+func (c *Client) postUser(ctx context.Context, path string, req interface{}, opts []CallOption) (*User, error) {
+	var resp struct {
+		User User `json:"user"`
+	}
+	if err := c.post(ctx, path, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// GetUserReviews lists all PRs the user is assigned to review
+func (c *Client) GetUserReviews(ctx context.Context, userID string) ([]PullRequestShort, error) {
+	return c.UserReviews(userID).All(ctx)
+}
+
+// UserReviews iterates over the PRs the user is assigned to review, in
+// pull_request_id order
+func (c *Client) UserReviews(userID string) *Iterator[PullRequestShort] {
+	return &Iterator[PullRequestShort]{fetch: func(ctx context.Context, cursor string) ([]PullRequestShort, string, error) {
+		var resp struct {
+			PullRequests []PullRequestShort `json:"pull_requests"`
+			NextCursor   string             `json:"next_cursor"`
+		}
+		query := pageQuery(url.Values{"user_id": {userID}}, cursor)
+		if err := c.get(ctx, "/users/getReview", query, &resp); err != nil {
+			return nil, "", err
+		}
+		return resp.PullRequests, resp.NextCursor, nil
+	}}
+}
+
+// CreatePullRequest creates a PR and assigns reviewers from the author's team
+func (c *Client) CreatePullRequest(ctx context.Context, req CreatePullRequestRequest, opts ...CallOption) (*PullRequest, error) {
+	return c.postPullRequest(ctx, "/pullRequest/create", req, opts)
+}
+
+// PreviewPullRequest shows which reviewers a new PR would get without
+// creating it
+func (c *Client) PreviewPullRequest(ctx context.Context, req PreviewRequest, opts ...CallOption) (*AssignmentPreview, error) {
+	var resp struct {
+		Preview AssignmentPreview `json:"preview"`
+	}
+	if err := c.post(ctx, "/pullRequest/preview", req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.Preview, nil
+}
+
+// MergePullRequest marks the PR as merged; merging a merged PR returns it
+// unchanged
+func (c *Client) MergePullRequest(ctx context.Context, prID string, opts ...CallOption) (*PullRequest, error) {
+	req := struct {
+		PullRequestID string `json:"pull_request_id"`
+	}{prID}
+	return c.postPullRequest(ctx, "/pullRequest/merge", req, opts)
+}
+
+func (c *Client) postPullRequest(ctx context.Context, path string, req interface{}, opts []CallOption) (*PullRequest, error) {
+	var resp struct {
+		PR PullRequest `json:"pr"`
+	}
+	if err := c.post(ctx, path, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.PR, nil
+}
+
+// ReassignReviewer replaces a reviewer with another member of their team
+// and returns the updated PR and the new reviewer's ID
+func (c *Client) ReassignReviewer(ctx context.Context, req ReassignRequest, opts ...CallOption) (*PullRequest, string, error) {
+	var resp struct {
+		PR         PullRequest `json:"pr"`
+		ReplacedBy string      `json:"replaced_by"`
+	}
+	if err := c.post(ctx, "/pullRequest/reassign", req, &resp, opts); err != nil {
+		return nil, "", err
+	}
+	return &resp.PR, resp.ReplacedBy, nil
+}
+
+// SubmitReview records a review with one of the Review states
+func (c *Client) SubmitReview(ctx context.Context, prID, reviewerID, state string, opts ...CallOption) (*Review, error) {
+	req := struct {
+		PullRequestID string `json:"pull_request_id"`
+		ReviewerID    string `json:"reviewer_id"`
+		State         string `json:"state"`
+	}{prID, reviewerID, state}
+	var resp struct {
+		Review Review `json:"review"`
+	}
+	if err := c.post(ctx, "/pullRequest/review", req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.Review, nil
+}
+
+func (c *Client) GetReviewerStats(ctx context.Context, filter StatsFilter) ([]ReviewerStats, error) {
+	var resp struct {
+		Reviewers []ReviewerStats `json:"reviewers"`
+	}
+	if err := c.get(ctx, "/stats/reviewers", statsQuery(filter), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Reviewers, nil
+}
+
+func (c *Client) GetPullRequestStats(ctx context.Context, filter StatsFilter) (*PullRequestStats, error) {
+	var resp struct {
+		PullRequests PullRequestStats `json:"pull_requests"`
+	}
+	if err := c.get(ctx, "/stats/pullRequests", statsQuery(filter), &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PullRequests, nil
+}
+
+func (c *Client) GetCycleTimeStats(ctx context.Context, filter StatsFilter) (*CycleTimeStats, error) {
+	var resp struct {
+		CycleTime CycleTimeStats `json:"cycle_time"`
+	}
+	if err := c.get(ctx, "/stats/cycleTime", statsQuery(filter), &resp); err != nil {
+		return nil, err
+	}
+	return &resp.CycleTime, nil
+}
+
+func statsQuery(filter StatsFilter) url.Values {
+	query := url.Values{}
+	if filter.TeamName != "" {
+		query.Set("team_name", filter.TeamName)
+	}
+	if filter.From != nil {
+		query.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if filter.To != nil {
+		query.Set("to", filter.To.Format(time.RFC3339))
+	}
+	return query
+}
+
+// IssueToken issues an API token. The secret is only returned here.
+func (c *Client) IssueToken(ctx context.Context, req IssueTokenRequest, opts ...CallOption) (*IssuedToken, error) {
+	var resp struct {
+		Token IssuedToken `json:"token"`
+	}
+	if err := c.post(ctx, "/tokens/issue", req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.Token, nil
+}
+
+// ListTokens lists all issued tokens, including revoked ones
+func (c *Client) ListTokens(ctx context.Context) ([]APIToken, error) {
+	return c.Tokens().All(ctx)
+}
+
+// Tokens iterates over the issued tokens, including revoked ones, in the
+// order they were issued
+func (c *Client) Tokens() *Iterator[APIToken] {
+	return &Iterator[APIToken]{fetch: func(ctx context.Context, cursor string) ([]APIToken, string, error) {
+		var resp struct {
+			Tokens     []APIToken `json:"tokens"`
+			NextCursor string     `json:"next_cursor"`
+		}
+		if err := c.get(ctx, "/tokens/list", pageQuery(url.Values{}, cursor), &resp); err != nil {
+			return nil, "", err
+		}
+		return resp.Tokens, resp.NextCursor, nil
+	}}
+}
+
+func (c *Client) RevokeToken(ctx context.Context, tokenID string, opts ...CallOption) (*APIToken, error) {
+	req := struct {
+		TokenID string `json:"token_id"`
+	}{tokenID}
+	var resp struct {
+		Token APIToken `json:"token"`
+	}
+	if err := c.post(ctx, "/tokens/revoke", req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp.Token, nil
+}
+
+// Live calls the liveness probe
+func (c *Client) Live(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.get(ctx, "/livez", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Ready calls the readiness probe. A service that isn't ready fails with
+// an *Error with status 503; its components are not decoded.
+func (c *Client) Ready(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.get(ctx, "/readyz", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
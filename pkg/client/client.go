@@ -0,0 +1,259 @@
+// Package client is a typed Go client for the PR reviewer assignment
+// service.
+//
+// POST requests carry an Idempotency-Key, generated per call unless one is
+// given with WithIdempotencyKey, so failed requests are retried without
+// being applied twice. Responses the service rejects are returned as
+// *Error; compare them with errors.Is against the Err sentinels.
+//
+// List endpoints are paged. Iterators such as UserReviews fetch the pages
+// as they are walked; GetUserReviews and ListTokens collect every page.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+	maxBackoff        = 5 * time.Second
+)
+
+// Client calls the service. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	userAgent  string
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithToken authenticates requests with an API token or JWT
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient replaces the default client, which has a 30s timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetries sets how often a failed request is retried and the delay
+// before the first retry, which doubles with each further one. Zero
+// retries disables retrying.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New creates a client for the service at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  "pr-reviewer-service-client",
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CallOption adjusts a single request
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	idempotencyKey string
+	ifMatch        int64
+}
+
+// WithIdempotencyKey sends key instead of a generated one, so a request
+// can be safely repeated across process restarts
+func WithIdempotencyKey(key string) CallOption {
+	return func(o *callOptions) { o.idempotencyKey = key }
+}
+
+// IfMatch only applies the change if the PR is still at version; otherwise
+// the call fails with ErrPreconditionFailed
+func IfMatch(version int64) CallOption {
+	return func(o *callOptions) { o.ifMatch = version }
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(ctx, http.MethodGet, path, nil, out, nil)
+}
+
+func (c *Client) post(ctx context.Context, path string, in, out interface{}, opts []CallOption) error {
+	var options callOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.idempotencyKey == "" {
+		options.idempotencyKey = newIdempotencyKey()
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, path, body, out, &options)
+}
+
+// do sends the request, retrying it while it fails in a way a retry may fix
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}, options *callOptions) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.send(ctx, method, path, body, out, options)
+		if err == nil || attempt >= c.maxRetries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+
+		delay := backoff
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		backoff = min(2*backoff, maxBackoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send makes one attempt. retryAfter is the delay the service asked for
+// with a Retry-After header.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out interface{}, options *callOptions) (retryAfter time.Duration, err error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if options != nil {
+		req.Header.Set("Idempotency-Key", options.idempotencyKey)
+		if options.ifMatch > 0 {
+			req.Header.Set("If-Match", `"`+strconv.FormatInt(options.ifMatch, 10)+`"`)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, &transportError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	if resp.StatusCode >= 300 {
+		return retryAfter, decodeError(resp)
+	}
+	if out == nil {
+		return 0, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("decode %s response: %w", path, err)
+	}
+	return 0, nil
+}
+
+// decodeError reads the service's error envelope; responses without one,
+// e.g. from a proxy, keep their body as the message
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var envelope struct {
+		Error struct {
+			Code    string       `json:"code"`
+			Message string       `json:"message"`
+			Details []FieldError `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Error.Code == "" {
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Code:       envelope.Error.Code,
+		Message:    envelope.Error.Message,
+		Details:    envelope.Error.Details,
+	}
+}
+
+// transportError is a request that got no response, e.g. because the
+// connection failed
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// retryable reports whether err may go away on retry. POST requests are
+// safe to repeat because they carry the same Idempotency-Key every time.
+// VERSION_CONFLICT is not retried: the service already retried the update
+// itself, and keeps the 409 as the key's response, so a retry would only
+// replay it. Calling the method again sends a new key.
+func retryable(err error) bool {
+	var transportErr *transportError
+	if errors.As(err, &transportErr) {
+		return true
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case CodeRateLimited, CodeIdempotencyInProgress:
+		return true
+	}
+	return apiErr.StatusCode >= 500
+}
+
+func newIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("read random idempotency key: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"pr-reviewer-service/internal/handlers"
+	"pr-reviewer-service/internal/idempotency"
+	"pr-reviewer-service/internal/models"
+	"pr-reviewer-service/internal/service"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostRetriesWithSameIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-Match") != `"4"` {
+			t.Errorf("expected If-Match \"4\", got %q", r.Header.Get("If-Match"))
+		}
+		w.Write([]byte(`{"pr": {"pull_request_id": "pr-1", "status": "MERGED", "version": 5}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetries(3, time.Millisecond))
+	pr, err := c.MergePullRequest(context.Background(), "pr-1", IfMatch(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Status != "MERGED" || pr.Version != 5 {
+		t.Errorf("unexpected PR %+v", pr)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("expected 3 attempts with one key, got %q", keys)
+	}
+}
+
+func TestErrorsMapToCodes(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"code": "VALIDATION_FAILED", "message": "request validation failed",
+			"details": [{"field": "author_id", "reason": "is required"}]}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetries(3, time.Millisecond))
+	_, err := c.CreatePullRequest(context.Background(), CreatePullRequestRequest{PullRequestID: "pr-1"})
+	if !errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || len(apiErr.Details) != 1 || apiErr.Details[0].Field != "author_id" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	if attempts != 1 {
+		t.Errorf("validation errors must not be retried, got %d attempts", attempts)
+	}
+}
+
+// memoryIdempotencyStore is an in-memory idempotency.Store without expiry
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*models.IdempotencyRecord
+}
+
+func (m *memoryIdempotencyStore) ClaimIdempotencyKey(ctx context.Context, scope, key, requestHash string, pendingTTL time.Duration) (*models.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[scope+"/"+key]; ok {
+		return record, nil
+	}
+	m.records[scope+"/"+key] = &models.IdempotencyRecord{RequestHash: requestHash}
+	return nil, nil
+}
+
+func (m *memoryIdempotencyStore) SaveIdempotentResponse(ctx context.Context, scope, key string, response *models.IdempotencyRecord, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *response
+	saved.RequestHash = m.records[scope+"/"+key].RequestHash
+	m.records[scope+"/"+key] = &saved
+	return nil
+}
+
+func (m *memoryIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, scope+"/"+key)
+	return nil
+}
+
+func (m *memoryIdempotencyStore) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestRetriesThroughIdempotencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		firstStatus  int
+		firstBody    string
+		wantErr      error
+		wantAttempts int
+		wantRequests int
+	}{
+		// 5xx responses release the key, so the retry runs the request again
+		{"server error", http.StatusServiceUnavailable, `{"error": {"code": "UNAVAILABLE", "message": "unavailable"}}`, nil, 2, 2},
+		// The 409 is stored as the key's response; a retry would replay it
+		{"version conflict", http.StatusConflict, `{"error": {"code": "VERSION_CONFLICT", "message": "retry"}}`, ErrVersionConflict, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.Header().Set("Content-Type", "application/json")
+				if attempts == 1 {
+					w.WriteHeader(tt.firstStatus)
+					w.Write([]byte(tt.firstBody))
+					return
+				}
+				w.Write([]byte(`{"pr": {"pull_request_id": "pr-1", "status": "MERGED", "version": 2}}`))
+			})
+			store := &memoryIdempotencyStore{records: map[string]*models.IdempotencyRecord{}}
+			middleware := idempotency.NewMiddleware(store, time.Hour).Handler(handler)
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				middleware.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+
+			c := New(server.URL, WithRetries(3, time.Millisecond))
+			_, err := c.MergePullRequest(context.Background(), "pr-1")
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("handler ran %d times, want %d", attempts, tt.wantAttempts)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("client sent %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// pagedStore serves review assignments the way the repository pages them
+type pagedStore struct {
+	service.Store
+	prs []models.PullRequestShort
+}
+
+func (s pagedStore) GetUserReviewPullRequests(ctx context.Context, userID string, page models.PageRequest) ([]models.PullRequestShort, error) {
+	prs := []models.PullRequestShort{}
+	for _, pr := range s.prs {
+		if pr.PullRequestID > page.Cursor && (page.Limit == 0 || len(prs) < page.Limit) {
+			prs = append(prs, pr)
+		}
+	}
+	return prs, nil
+}
+
+func TestUserReviewsWalksAllPages(t *testing.T) {
+	store := pagedStore{}
+	for i := 0; i < 2*defaultPageSize+1; i++ {
+		store.prs = append(store.prs, models.PullRequestShort{PullRequestID: fmt.Sprintf("pr-%04d", i)})
+	}
+	h := handlers.NewHandlers(service.NewService(store, rand.NewSource(1), models.StrategyRandom))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		h.GetUserReviewPullRequests(w, r)
+	}))
+	defer server.Close()
+
+	prs, err := New(server.URL).GetUserReviews(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != len(store.prs) {
+		t.Fatalf("got %d PRs, want %d", len(prs), len(store.prs))
+	}
+	for i, pr := range prs {
+		if pr.PullRequestID != store.prs[i].PullRequestID {
+			t.Fatalf("PR %d is %s, want %s", i, pr.PullRequestID, store.prs[i].PullRequestID)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("fetched %d pages, want 3", requests)
+	}
+}
+
+func TestIteratorStopsAtError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": {"code": "FORBIDDEN", "message": "forbidden"}}`))
+			return
+		}
+		w.Write([]byte(`{"tokens": [{"token_id": "tok_1"}, {"token_id": "tok_2"}], "next_cursor": "tok_2"}`))
+	}))
+	defer server.Close()
+
+	it := New(server.URL).Tokens()
+	var ids []string
+	for it.Next(context.Background()) {
+		ids = append(ids, it.Item().TokenID)
+	}
+	if len(ids) != 2 || !errors.Is(it.Err(), ErrForbidden) {
+		t.Errorf("got %v, %v; want the first page, then ErrForbidden", ids, it.Err())
+	}
+	if it.Next(context.Background()) {
+		t.Error("Next continued after an error")
+	}
+}
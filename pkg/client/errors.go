@@ -0,0 +1,97 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error codes returned by the service
+const (
+	CodeValidationFailed      = "VALIDATION_FAILED"
+	CodeInvalidRequest        = "INVALID_REQUEST"
+	CodeMissingParameter      = "MISSING_PARAMETER"
+	CodeInvalidParameter      = "INVALID_PARAMETER"
+	CodeBodyTooLarge          = "BODY_TOO_LARGE"
+	CodeUnauthorized          = "UNAUTHORIZED"
+	CodeForbidden             = "FORBIDDEN"
+	CodeNotFound              = "NOT_FOUND"
+	CodeTeamExists            = "TEAM_EXISTS"
+	CodePRExists              = "PR_EXISTS"
+	CodePRMerged              = "PR_MERGED"
+	CodeNotAssigned           = "NOT_ASSIGNED"
+	CodeNoCandidate           = "NO_CANDIDATE"
+	CodeUnknownStrategy       = "UNKNOWN_STRATEGY"
+	CodeInvalidPolicy         = "INVALID_POLICY"
+	CodeInvalidState          = "INVALID_STATE"
+	CodeUnknownRole           = "UNKNOWN_ROLE"
+	CodeVersionConflict       = "VERSION_CONFLICT"
+	CodePreconditionFailed    = "PRECONDITION_FAILED"
+	CodeDeclineQuotaExceeded  = "DECLINE_QUOTA_EXCEEDED"
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeIdempotencyInProgress = "IDEMPOTENCY_IN_PROGRESS"
+	CodeIdempotencyKeyReused  = "IDEMPOTENCY_KEY_REUSED"
+	CodeInvalidIdempotencyKey = "INVALID_IDEMPOTENCY_KEY"
+	CodeInternalError         = "INTERNAL_ERROR"
+)
+
+// Sentinels for errors.Is; an *Error matches the sentinel with its code
+var (
+	ErrValidationFailed     = &Error{Code: CodeValidationFailed}
+	ErrUnauthorized         = &Error{Code: CodeUnauthorized}
+	ErrForbidden            = &Error{Code: CodeForbidden}
+	ErrNotFound             = &Error{Code: CodeNotFound}
+	ErrTeamExists           = &Error{Code: CodeTeamExists}
+	ErrPRExists             = &Error{Code: CodePRExists}
+	ErrPRMerged             = &Error{Code: CodePRMerged}
+	ErrNotAssigned          = &Error{Code: CodeNotAssigned}
+	ErrNoCandidate          = &Error{Code: CodeNoCandidate}
+	ErrVersionConflict      = &Error{Code: CodeVersionConflict}
+	ErrPreconditionFailed   = &Error{Code: CodePreconditionFailed}
+	ErrDeclineQuotaExceeded = &Error{Code: CodeDeclineQuotaExceeded}
+	ErrRateLimited          = &Error{Code: CodeRateLimited}
+	ErrIdempotencyKeyReused = &Error{Code: CodeIdempotencyKeyReused}
+)
+
+// FieldError describes why one field of a request failed validation
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Error is an error response from the service
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// Details lists the invalid fields of a VALIDATION_FAILED error
+	Details []FieldError
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	if len(e.Details) > 0 {
+		fields := make([]string, len(e.Details))
+		for i, detail := range e.Details {
+			fields[i] = detail.Field + " " + detail.Reason
+		}
+		msg += " (" + strings.Join(fields, "; ") + ")"
+	}
+	return msg
+}
+
+// Is reports whether target is an *Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// ErrorCode returns the service's error code for err, or "" if err isn't
+// an error response
+func ErrorCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
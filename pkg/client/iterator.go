@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// defaultPageSize is how many items an Iterator fetches per request
+const defaultPageSize = 100
+
+// Iterator walks a list endpoint page by page, fetching the next page when
+// the current one runs out:
+//
+//	it := c.UserReviews("u1")
+//	for it.Next(ctx) {
+//		pr := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	fetch func(ctx context.Context, cursor string) ([]T, string, error)
+
+	page   []T
+	cursor string
+	last   bool
+	item   T
+	err    error
+}
+
+// Next advances to the next item, fetching a page if needed. It returns
+// false at the end of the list or on an error; see Err.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for len(it.page) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		it.page, it.cursor, it.err = it.fetch(ctx, it.cursor)
+		it.last = it.cursor == ""
+	}
+	it.item, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Item returns the item Next advanced to
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All collects the remaining items
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	items := []T{}
+	for it.Next(ctx) {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// pageQuery adds the paging parameters for the page after cursor to query
+func pageQuery(query url.Values, cursor string) url.Values {
+	query.Set("limit", strconv.Itoa(defaultPageSize))
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	return query
+}
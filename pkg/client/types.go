@@ -0,0 +1,91 @@
+package client
+
+import "pr-reviewer-service/internal/models"
+
+// The client uses the server's own types, so their JSON field names can't
+// drift apart
+type (
+	User               = models.User
+	Team               = models.Team
+	TeamPolicy         = models.TeamPolicy
+	PullRequest        = models.PullRequest
+	PullRequestShort   = models.PullRequestShort
+	Review             = models.Review
+	AssignmentPreview  = models.AssignmentPreview
+	CandidateExclusion = models.CandidateExclusion
+	ReviewerStats      = models.ReviewerStats
+	PullRequestStats   = models.PullRequestStats
+	Percentiles        = models.Percentiles
+	CycleTimeBucket    = models.CycleTimeBucket
+	CycleTimeStats     = models.CycleTimeStats
+	APIToken           = models.APIToken
+	IssuedToken        = models.IssuedToken
+
+	// MemberChange adds a user to a team; nil fields keep an existing
+	// user's value
+	MemberChange = models.MemberChange
+
+	// StatsFilter narrows statistics to a team and a [From, To) time range;
+	// zero fields don't filter
+	StatsFilter = models.StatsFilter
+)
+
+// Review states
+const (
+	ReviewCommented        = models.ReviewCommented
+	ReviewApproved         = models.ReviewApproved
+	ReviewChangesRequested = models.ReviewChangesRequested
+)
+
+// API token roles
+const (
+	RoleAdmin    = models.RoleAdmin
+	RoleTeamLead = models.RoleTeamLead
+	RoleBot      = models.RoleBot
+	RoleReader   = models.RoleReader
+)
+
+// CreatePullRequestRequest is the input of CreatePullRequest
+type CreatePullRequestRequest struct {
+	PullRequestID   string `json:"pull_request_id"`
+	PullRequestName string `json:"pull_request_name"`
+	AuthorID        string `json:"author_id"`
+}
+
+// PreviewRequest is the input of PreviewPullRequest. PullRequestID is only
+// needed for the pr_seeded strategy; Strategy defaults to the team's.
+type PreviewRequest struct {
+	PullRequestID string `json:"pull_request_id,omitempty"`
+	AuthorID      string `json:"author_id"`
+	Strategy      string `json:"strategy,omitempty"`
+	Seed          *int64 `json:"seed,omitempty"`
+}
+
+// IssueTokenRequest is the input of IssueToken. TeamName is required for
+// team-lead tokens only; UserID lets the token act as that user, e.g. to
+// decline the user's review assignments.
+type IssueTokenRequest struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	TeamName string `json:"team_name,omitempty"`
+	UserID   string `json:"user_id,omitempty"`
+}
+
+// ReassignRequest is the input of ReassignReviewer
+type ReassignRequest struct {
+	PullRequestID string `json:"pull_request_id"`
+	OldUserID     string `json:"old_user_id"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// Health is the response of the probes
+type Health struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+type ComponentHealth struct {
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Detail interface{} `json:"detail,omitempty"`
+}